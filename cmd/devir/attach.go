@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/x/term"
+
+	"devir/internal/config"
+	"devir/internal/daemon"
+)
+
+// detachKey is ctrl+], which ends an attach session
+const detachKey = 0x1d
+
+// attachSession forwards the terminal's input to a service and prints the
+// service's output
+type attachSession struct {
+	client  *daemon.Client
+	service string
+	tty     bool // The service runs under a pseudo-terminal
+	raw     bool // Our terminal is in raw mode
+
+	mu   sync.Mutex
+	line []byte // Typed input not sent yet (services without a tty)
+}
+
+// runAttach connects to the running daemon and attaches to a service
+func runAttach(cfg *config.Config, socketPath string, args []string) {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: devir attach <service>")
+		os.Exit(1)
+	}
+	name := args[0]
+	svc, ok := cfg.Services[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown service: %s\n", name)
+		os.Exit(1)
+	}
+
+	if !daemon.Exists(socketPath) {
+		fmt.Fprintln(os.Stderr, "No devir daemon is running for this project")
+		os.Exit(1)
+	}
+	client, err := daemon.Connect(socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = client.Close() }()
+	warnMismatches(client.Mismatches(cfg))
+
+	s := &attachSession{client: client, service: name, tty: svc.TTY}
+
+	// Show where the service is at before following its output
+	logs, err := client.LogsSync(name, 20, 5*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to get logs: %v\n", err)
+		os.Exit(1)
+	}
+	for _, log := range logs {
+		s.print(log)
+	}
+
+	client.OnMessage(daemon.MsgLogEntry, func(msg daemon.Message) {
+		if log, err := daemon.ParsePayload[daemon.LogEntryData](msg); err == nil && log.Service == name {
+			s.print(log)
+		}
+	})
+	client.OnMessage(daemon.MsgError, func(msg daemon.Message) {
+		resp, _ := daemon.ParsePayload[daemon.ErrorResponse](msg)
+		s.printText("devir: " + resp.Error)
+	})
+
+	// Only this service's output is needed. Lines logged since the request
+	// above are replayed, so none are missed.
+	if client.HasCapability("subscribe") {
+		var last uint64
+		if len(logs) > 0 {
+			last = logs[len(logs)-1].Seq
+		}
+		missed, err := client.Resume([]string{name}, "", "", last, 5*time.Second)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to subscribe: %v\n", err)
+			os.Exit(1)
+		}
+		for _, log := range missed {
+			s.print(log)
+		}
+	}
+
+	// Input from a pipe is sent line by line until it ends
+	fd := os.Stdin.Fd()
+	if !term.IsTerminal(fd) {
+		s.forwardLines(os.Stdin)
+		return
+	}
+
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to set up terminal: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = term.Restore(fd, state) }()
+	s.raw = true
+
+	s.printText(fmt.Sprintf("Attached to %s (ctrl+] to detach)", name))
+	s.forwardKeys(os.Stdin)
+	s.printText("Detached")
+}
+
+// forwardKeys sends typed keys until the detach key or the end of input
+func (s *attachSession) forwardKeys(in io.Reader) {
+	buf := make([]byte, 1024)
+	for {
+		n, err := in.Read(buf)
+		data := buf[:n]
+		i := bytes.IndexByte(data, detachKey)
+		if i >= 0 {
+			data = data[:i]
+		}
+		if len(data) > 0 {
+			if s.tty {
+				// The pseudo-terminal echoes and edits like a real one
+				_ = s.client.Stdin(s.service, data)
+			} else {
+				s.edit(data)
+			}
+		}
+		if i >= 0 || err != nil {
+			return
+		}
+	}
+}
+
+// edit applies typed bytes to the pending line of a service without a tty,
+// sending it on Enter
+func (s *attachSession) edit(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range data {
+		switch b {
+		case '\r', '\n':
+			_ = s.client.Stdin(s.service, append(s.line, '\n'))
+			s.line = nil
+			fmt.Print("\r\n")
+		case 0x7f, '\b':
+			if len(s.line) > 0 {
+				_, size := utf8.DecodeLastRune(s.line)
+				s.line = s.line[:len(s.line)-size]
+				fmt.Print("\b \b")
+			}
+		case 0x03, 0x15:
+			// ctrl+c and ctrl+u discard the line
+			s.line = nil
+			fmt.Print("\r\x1b[K")
+		default:
+			if b >= 0x20 {
+				s.line = append(s.line, b)
+				_, _ = os.Stdout.Write([]byte{b})
+			}
+		}
+	}
+}
+
+// forwardLines sends piped input line by line
+func (s *attachSession) forwardLines(in io.Reader) {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		_ = s.client.Stdin(s.service, []byte(scanner.Text()+"\n"))
+	}
+	// Give the daemon a moment to report errors and the service to respond
+	time.Sleep(500 * time.Millisecond)
+}
+
+func (s *attachSession) print(log daemon.LogEntryData) {
+	text := log.Message
+	if log.Styled != "" {
+		text = log.Styled + "\x1b[0m"
+	}
+	s.printText(text)
+}
+
+// printText prints a line of output above the pending input
+func (s *attachSession) printText(text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.raw {
+		fmt.Println(text)
+		return
+	}
+	// Raw mode doesn't turn \n into \r\n
+	text = strings.ReplaceAll(text, "\n", "\r\n")
+	fmt.Printf("\r\x1b[K%s\r\n%s", text, s.line)
+}
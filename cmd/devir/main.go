@@ -42,6 +42,7 @@ func init() {
 
 func main() {
 	flag.Parse()
+	daemon.Version = Version
 
 	if showVersion {
 		fmt.Printf("devir %s\n", Version)
@@ -70,6 +71,26 @@ func main() {
 	// Get socket path based on config directory
 	socketPath := daemon.SocketPath(cfg.RootDir)
 
+	if len(args) > 0 {
+		switch args[0] {
+		case "attach":
+			runAttach(cfg, socketPath, args[1:])
+			return
+		case "up":
+			runUp(cfg, socketPath, args[1:])
+			return
+		case "down":
+			runDown(cfg, socketPath)
+			return
+		case "reload":
+			runReload(socketPath)
+			return
+		case "daemon":
+			runDaemon(cfg, socketPath)
+			return
+		}
+	}
+
 	// MCP mode
 	if mcpMode {
 		runMCPMode(cfg, socketPath)
@@ -91,6 +112,9 @@ func runMCPMode(cfg *config.Config, socketPath string) {
 		}
 		defer func() { _ = client.Close() }()
 
+		// stdin belongs to the MCP protocol, so only warn
+		warnMismatches(client.Mismatches(cfg))
+
 		mcpServer := mcp.NewWithClient(cfg, client, Version)
 		if err := mcpServer.Run(); err != nil {
 			fmt.Fprintf(os.Stderr, "MCP error: %v\n", err)
@@ -123,23 +147,7 @@ func runMCPMode(cfg *config.Config, socketPath string) {
 }
 
 func runTUIMode(cfg *config.Config, socketPath string) {
-	services := flag.Args()
-	if len(services) == 0 {
-		services = cfg.Defaults
-	}
-
-	// Validate services
-	for _, name := range services {
-		if _, ok := cfg.Services[name]; !ok {
-			fmt.Fprintf(os.Stderr, "Unknown service: %s\n", name)
-			fmt.Fprintf(os.Stderr, "Available: ")
-			for k := range cfg.Services {
-				fmt.Fprintf(os.Stderr, "%s ", k)
-			}
-			fmt.Fprintln(os.Stderr)
-			os.Exit(1)
-		}
-	}
+	services := resolveServices(cfg, flag.Args())
 
 	// Check if daemon already exists
 	if daemon.Exists(socketPath) {
@@ -151,6 +159,18 @@ func runTUIMode(cfg *config.Config, socketPath string) {
 		}
 		defer func() { _ = client.Close() }()
 
+		if diffs := client.Mismatches(cfg); len(diffs) > 0 {
+			warnMismatches(diffs)
+			fmt.Print("Continue with the running daemon? [Y/n] ")
+
+			var answer string
+			_, _ = fmt.Scanln(&answer)
+
+			if answer == "n" || answer == "N" {
+				os.Exit(1)
+			}
+		}
+
 		// Start TUI with client
 		p := tea.NewProgram(
 			tui.NewWithClient(client, services, cfg),
@@ -174,24 +194,7 @@ func runTUIMode(cfg *config.Config, socketPath string) {
 	defer d.Stop()
 
 	// Check for ports in use
-	r := runner.New(cfg, services, filter, exclude)
-	portsInUse := r.CheckPorts()
-	killPorts := false
-
-	if len(portsInUse) > 0 {
-		fmt.Println("\n⚠️  Aşağıdaki portlar zaten kullanımda:")
-		for name, port := range portsInUse {
-			fmt.Printf("   • %s: port %d\n", name, port)
-		}
-		fmt.Print("\nBu portları kapatıp devam edilsin mi? [y/N] ")
-
-		var answer string
-		_, _ = fmt.Scanln(&answer)
-
-		if answer == "y" || answer == "Y" {
-			killPorts = true
-		}
-	}
+	killPorts := confirmKillPorts(cfg, services)
 
 	// Connect as client
 	client, err := daemon.Connect(socketPath)
@@ -221,15 +224,76 @@ func runTUIMode(cfg *config.Config, socketPath string) {
 	}
 }
 
+// resolveServices validates the services named on the command line (the
+// defaults if none) and adds their dependencies
+func resolveServices(cfg *config.Config, services []string) []string {
+	if len(services) == 0 {
+		services = cfg.Defaults
+	}
+
+	// Validate services
+	for _, name := range services {
+		if _, ok := cfg.Services[name]; !ok {
+			fmt.Fprintf(os.Stderr, "Unknown service: %s\n", name)
+			fmt.Fprintf(os.Stderr, "Available: ")
+			for k := range cfg.Services {
+				fmt.Fprintf(os.Stderr, "%s ", k)
+			}
+			fmt.Fprintln(os.Stderr)
+			os.Exit(1)
+		}
+	}
+
+	// Include dependencies so they get their own tabs
+	return cfg.ResolveDependencies(services)
+}
+
+// confirmKillPorts asks whether to free the ports of services that are
+// already in use
+func confirmKillPorts(cfg *config.Config, services []string) bool {
+	r := runner.New(cfg, services, filter, exclude)
+	portsInUse := r.CheckPorts()
+	if len(portsInUse) == 0 {
+		return false
+	}
+
+	fmt.Println("\n⚠️  Aşağıdaki portlar zaten kullanımda:")
+	for name, port := range portsInUse {
+		fmt.Printf("   • %s: port %d\n", name, port)
+	}
+	fmt.Print("\nBu portları kapatıp devam edilsin mi? [y/N] ")
+
+	var answer string
+	_, _ = fmt.Scanln(&answer)
+
+	return answer == "y" || answer == "Y"
+}
+
+// warnMismatches tells the user how a running daemon differs from this devir
+func warnMismatches(diffs []string) {
+	for _, d := range diffs {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", d)
+	}
+}
+
 func printHelp() {
 	fmt.Printf(`devir %s - Dev Runner CLI
 
 Usage:
   devir [options] [services...]
   devir init               # Create devir.yaml
+  devir attach <service>   # Type into a running service
+  devir up [services...]   # Start services in the background
+  devir down               # Stop the background daemon
+  devir reload             # Apply devir.yaml changes to the daemon
 
 Commands:
   init          Create devir.yaml in current directory
+  attach        Forward keyboard input to a service (ctrl+] to detach)
+  up            Start a detached daemon and its services
+  down          Stop the services and the detached daemon
+  reload        Reload devir.yaml (also done when it is saved)
+  daemon        Run the daemon in the foreground (used by up)
 
 Options:
   -c <file>     Config file path (default: devir.yaml)
@@ -250,6 +314,8 @@ Examples:
 Daemon Mode:
   Multiple TUI/MCP clients can connect to same daemon.
   First instance starts daemon, others connect automatically.
+  With devir up the daemon runs on its own, and quitting a
+  TUI or MCP client leaves the services running.
 
 Keyboard Shortcuts:
   Tab          Cycle through services
@@ -258,6 +324,7 @@ Keyboard Shortcuts:
   /            Search
   c            Copy logs to clipboard
   r            Restart current service
+  i            Attach to current service (ctrl+] to detach)
   j/k          Scroll up/down
   q            Quit
 `, Version)
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"devir/internal/config"
+	"devir/internal/daemon"
+)
+
+const (
+	// daemonStartTimeout is how long devir up waits for the daemon's socket
+	daemonStartTimeout = 10 * time.Second
+
+	// shutdownTimeout covers stopping every service, each of which may take
+	// its stop_timeout
+	shutdownTimeout = time.Minute
+)
+
+// pidFilePath returns where a detached daemon records its process ID
+func pidFilePath(cfg *config.Config) string {
+	return filepath.Join(cfg.RootDir, ".devir", "daemon.pid")
+}
+
+// daemonLogPath returns where a detached daemon's output goes
+func daemonLogPath(cfg *config.Config) string {
+	return filepath.Join(cfg.RootDir, ".devir", "daemon.log")
+}
+
+// readPID returns the process ID in the pidfile, or 0 if there is none
+func readPID(cfg *config.Config) int {
+	data, err := os.ReadFile(pidFilePath(cfg))
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
+
+// runUp starts a daemon in its own process, which keeps the services running
+// after this command and any TUI or MCP clients exit
+func runUp(cfg *config.Config, socketPath string, args []string) {
+	services := resolveServices(cfg, args)
+
+	if daemon.Exists(socketPath) {
+		addServices(cfg, socketPath, services)
+		return
+	}
+
+	killPorts := confirmKillPorts(cfg, services)
+
+	if err := os.MkdirAll(filepath.Dir(daemonLogPath(cfg)), 0755); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create .devir: %v\n", err)
+		os.Exit(1)
+	}
+	logFile, err := os.OpenFile(daemonLogPath(cfg), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to open daemon log: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = logFile.Close() }()
+
+	exe, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to find devir executable: %v\n", err)
+		os.Exit(1)
+	}
+
+	// The same config path and working directory give the same socket path
+	cmd := exec.Command(exe, "-c", cfg.Path, "-ws-port", strconv.Itoa(wsPort), "daemon")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detachProcess(cmd)
+
+	if err := cmd.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start daemon: %v\n", err)
+		os.Exit(1)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	if err := waitForDaemon(socketPath, exited); err != nil {
+		fmt.Fprintf(os.Stderr, "%v (see %s)\n", err, daemonLogPath(cfg))
+		os.Exit(1)
+	}
+
+	client, err := daemon.Connect(socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = client.Close() }()
+
+	if _, err := client.StartAndWait(services, killPorts, 10*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start services: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ devir daemon started (pid %d) with %s\n", cmd.Process.Pid, strings.Join(services, ", "))
+	fmt.Printf("  Logs: %s\n", daemonLogPath(cfg))
+	fmt.Println("\nRun 'devir' to watch the services and 'devir down' to stop them.")
+}
+
+// addServices starts services in an already running daemon, leaving the
+// others alone
+func addServices(cfg *config.Config, socketPath string, services []string) {
+	client, err := daemon.Connect(socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = client.Close() }()
+	warnMismatches(client.Mismatches(cfg))
+
+	resp, err := client.StartAndWait(services, false, 10*time.Second)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start services: %v\n", err)
+		os.Exit(1)
+	}
+
+	if len(resp.Started) == 0 {
+		fmt.Println("devir daemon is already running these services")
+		return
+	}
+	fmt.Printf("✓ Started %s in the running devir daemon\n", strings.Join(resp.Started, ", "))
+}
+
+// waitForDaemon waits until the daemon accepts connections or its process
+// exits
+func waitForDaemon(socketPath string, exited <-chan error) error {
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(daemonStartTimeout)
+
+	for {
+		select {
+		case err := <-exited:
+			if err != nil {
+				return fmt.Errorf("daemon exited: %w", err)
+			}
+			return fmt.Errorf("daemon exited")
+		case <-timeout:
+			return fmt.Errorf("daemon did not start within %s", daemonStartTimeout)
+		case <-ticker.C:
+			if daemon.Exists(socketPath) {
+				return nil
+			}
+		}
+	}
+}
+
+// runDown stops the services and the detached daemon
+func runDown(cfg *config.Config, socketPath string) {
+	if !daemon.Exists(socketPath) {
+		_ = os.Remove(pidFilePath(cfg))
+		fmt.Println("No devir daemon is running for this project")
+		return
+	}
+
+	client, err := daemon.Connect(socketPath)
+	if err == nil && client.HasCapability("shutdown") {
+		err = client.Shutdown(shutdownTimeout)
+		_ = client.Close()
+
+		// The daemon may close the connection before the reply gets out
+		if err != nil && daemon.Exists(socketPath) {
+			fmt.Fprintf(os.Stderr, "Failed to stop daemon: %v\n", err)
+			os.Exit(1)
+		}
+	} else {
+		if client != nil {
+			_ = client.Close()
+		}
+		// A daemon we can't talk to, such as one from another devir
+		// version, can still be stopped through its pidfile
+		if err := signalDaemon(cfg); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to stop daemon: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	deadline := time.Now().Add(shutdownTimeout)
+	for daemon.Exists(socketPath) {
+		if time.Now().After(deadline) {
+			fmt.Fprintln(os.Stderr, "Daemon is still running")
+			os.Exit(1)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	fmt.Println("✓ devir daemon stopped")
+}
+
+// runReload makes the running daemon reload devir.yaml
+func runReload(socketPath string) {
+	if !daemon.Exists(socketPath) {
+		fmt.Fprintln(os.Stderr, "No devir daemon is running for this project")
+		os.Exit(1)
+	}
+	client, err := daemon.Connect(socketPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to daemon: %v\n", err)
+		os.Exit(1)
+	}
+	defer func() { _ = client.Close() }()
+
+	change, err := client.Reload(shutdownTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reload: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println("✓ Config reloaded")
+	for _, group := range []struct {
+		label    string
+		services []string
+	}{
+		{"Added", change.Added},
+		{"Removed", change.Removed},
+		{"Changed", change.Changed},
+		{"Started", change.Started},
+	} {
+		if len(group.services) > 0 {
+			fmt.Printf("  %s: %s\n", group.label, strings.Join(group.services, ", "))
+		}
+	}
+}
+
+// signalDaemon asks the process in the pidfile to stop
+func signalDaemon(cfg *config.Config) error {
+	pid := readPID(cfg)
+	if pid == 0 {
+		return fmt.Errorf("no pidfile at %s; the daemon belongs to a devir TUI or MCP session", pidFilePath(cfg))
+	}
+	proc, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return proc.Signal(syscall.SIGTERM)
+}
+
+// runDaemon runs a daemon without a TUI or MCP session until it receives a
+// shutdown message or a signal. devir up starts it in the background.
+func runDaemon(cfg *config.Config, socketPath string) {
+	if daemon.Exists(socketPath) {
+		fmt.Fprintln(os.Stderr, "A devir daemon is already running for this project")
+		os.Exit(1)
+	}
+
+	d := daemon.NewWithWSPort(cfg, socketPath, wsPort)
+	shutdown := d.AcceptShutdown()
+	if err := d.Start(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start daemon: %v\n", err)
+		os.Exit(1)
+	}
+
+	pidFile := pidFilePath(cfg)
+	if err := os.MkdirAll(filepath.Dir(pidFile), 0755); err == nil {
+		_ = os.WriteFile(pidFile, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644)
+	}
+	defer func() {
+		// Leave a newer daemon's pidfile alone
+		if readPID(cfg) == os.Getpid() {
+			_ = os.Remove(pidFile)
+		}
+	}()
+
+	log.Printf("devir %s daemon started (pid %d, socket %s)", Version, os.Getpid(), socketPath)
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case <-shutdown:
+		log.Printf("shutdown requested")
+	case sig := <-sigCh:
+		log.Printf("received %s", sig)
+	}
+
+	d.Stop()
+	log.Printf("daemon stopped")
+}
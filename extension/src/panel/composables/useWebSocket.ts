@@ -6,6 +6,17 @@ export interface LogMessage {
   service: string
   level: string
   message: string
+  fields?: Record<string, string>
+  seq?: number
+  stream?: 'stdout' | 'stderr' | 'devir'
+}
+
+// 'logs' answers the logs action; 'history' holds the entries missed while
+// disconnected
+export interface LogsMessage {
+  type: 'logs' | 'history'
+  logs: LogMessage[]
+  cursor?: string
 }
 
 export interface StatusMessage {
@@ -13,14 +24,35 @@ export interface StatusMessage {
   services: ServiceStatus[]
 }
 
+export interface StatusChangedMessage {
+  type: 'status_changed'
+  service: ServiceStatus
+}
+
+export interface ConfigChangedMessage {
+  type: 'config_changed'
+  added?: string[]
+  removed?: string[]
+  changed?: string[]
+  started?: string[]
+  configHash?: string
+  error?: string
+}
+
 export interface ServiceStatus {
   name: string
   running: boolean
-  status: 'running' | 'stopped' | 'completed' | 'failed' | 'waiting'
+  status: 'running' | 'starting' | 'healthy' | 'unhealthy' | 'restarting' | 'crashloop' | 'stopped' | 'completed' | 'failed' | 'waiting'
+  message?: string
   type?: 'service' | 'oneshot' | 'interval' | 'http'
   port?: number
   color: string
   icon?: string
+  exitCode?: number
+  runCount?: number
+  restarts?: number
+  crashLoop?: boolean
+  stopResult?: 'graceful' | 'killed'
 }
 
 export interface ResponseMessage {
@@ -30,7 +62,7 @@ export interface ResponseMessage {
   error?: string
 }
 
-export type WSMessage = LogMessage | StatusMessage | ResponseMessage
+export type WSMessage = LogMessage | LogsMessage | StatusMessage | StatusChangedMessage | ConfigChangedMessage | ResponseMessage
 
 const WS_URL = 'ws://localhost:9222/logs'
 const RECONNECT_DELAY = 3000
@@ -43,6 +75,9 @@ export function useWebSocket() {
   let reconnectTimeout: ReturnType<typeof setTimeout> | null = null
   const messageHandlers: ((msg: WSMessage) => void)[] = []
 
+  // Seq of the last live entry, so a reconnect gets what was missed
+  let lastSeq = 0
+
   function connect() {
     if (reconnectTimeout) {
       clearTimeout(reconnectTimeout)
@@ -52,7 +87,7 @@ export function useWebSocket() {
     status.value = 'connecting'
 
     try {
-      ws.value = new WebSocket(WS_URL)
+      ws.value = new WebSocket(lastSeq > 0 ? `${WS_URL}?after=${lastSeq}` : WS_URL)
 
       ws.value.onopen = () => {
         status.value = 'connected'
@@ -63,8 +98,23 @@ export function useWebSocket() {
         try {
           const data = JSON.parse(event.data) as WSMessage
 
-          if (data.type === 'status') {
+          if (data.type === 'log') {
+            lastSeq = Math.max(lastSeq, data.seq ?? 0)
+          } else if (data.type === 'history') {
+            data.logs.forEach(l => { lastSeq = Math.max(lastSeq, l.seq ?? 0) })
+          } else if (data.type === 'status') {
             services.value = (data as StatusMessage).services
+          } else if (data.type === 'status_changed') {
+            const changed = (data as StatusChangedMessage).service
+            const i = services.value.findIndex(s => s.name === changed.name)
+            if (i >= 0) {
+              services.value[i] = changed
+            } else {
+              services.value.push(changed)
+            }
+          } else if (data.type === 'config_changed') {
+            const removed = (data as ConfigChangedMessage).removed ?? []
+            services.value = services.value.filter(s => !removed.includes(s.name))
           }
 
           messageHandlers.forEach(handler => handler(data))
@@ -93,7 +143,19 @@ export function useWebSocket() {
     }
   }
 
-  function sendCommand(cmd: { action: string; service?: string }) {
+  function sendCommand(cmd: {
+    action: string
+    service?: string
+    // subscribe and logs filters
+    services?: string[]
+    level?: string
+    match?: string
+    // logs options
+    lines?: number
+    since?: string
+    until?: string
+    cursor?: string
+  }) {
     if (ws.value && ws.value.readyState === WebSocket.OPEN) {
       ws.value.send(JSON.stringify(cmd))
     }
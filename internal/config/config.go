@@ -1,9 +1,15 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -20,10 +26,30 @@ const (
 	ServiceTypeHTTP     ServiceType = "http"     // HTTP request
 )
 
+// RestartPolicy defines when a long-running service is restarted after it exits
+type RestartPolicy string
+
+const (
+	RestartNo        RestartPolicy = "no"         // Never restart (default)
+	RestartOnFailure RestartPolicy = "on-failure" // Restart on non-zero exit
+	RestartAlways    RestartPolicy = "always"     // Restart on any exit not requested by the user
+)
+
+// LogFormat defines how a service's output lines are parsed
+type LogFormat string
+
+const (
+	LogFormatText   LogFormat = "text"   // Plain text, level guessed from the content (default)
+	LogFormatJSON   LogFormat = "json"   // One JSON object per line (zap, pino, slog)
+	LogFormatLogfmt LogFormat = "logfmt" // key=value pairs (logrus, slog text handler)
+)
+
 // Service represents a single service configuration
 type Service struct {
 	Dir      string        `yaml:"dir"`
 	Cmd      string        `yaml:"cmd"`
+	Args     []string      `yaml:"args"`  // exact argv, used instead of cmd
+	Shell    *bool         `yaml:"shell"` // run cmd through the shell (default: top-level shell)
 	Port     int           `yaml:"port"`
 	Color    string        `yaml:"color"`
 	Icon     string        `yaml:"icon"`     // custom icon/emoji for display
@@ -33,6 +59,102 @@ type Service struct {
 	Method   string        `yaml:"method"`   // GET, POST, etc.
 	Body     string        `yaml:"body"`     // request body
 	Headers  []string      `yaml:"headers"`  // custom headers (key: value format)
+
+	DependsOn []string    `yaml:"depends_on"` // services that must be ready before this one starts
+	Ready     *ReadyCheck `yaml:"ready"`      // readiness probe for long-running services
+
+	Restart     RestartPolicy `yaml:"restart"`      // no, on-failure, always
+	MaxRestarts int           `yaml:"max_restarts"` // give up after this many consecutive restarts (0 = unlimited)
+	BackoffBase time.Duration `yaml:"backoff_base"` // first restart delay, doubled on each attempt (default 1s)
+	BackoffMax  time.Duration `yaml:"backoff_max"`  // restart delay cap; runs longer than this reset the count (default 30s)
+
+	Env          map[string]string `yaml:"env"`            // environment variables, override env_file and top-level env
+	EnvFile      []string          `yaml:"env_file"`       // dotenv files, relative to dir
+	NoDefaultEnv bool              `yaml:"no_default_env"` // don't set CI, TERM, NO_COLOR, FORCE_COLOR
+
+	Watch *WatchConfig `yaml:"watch"` // restart the service when files under dir change
+
+	StopSignal  string        `yaml:"stop_signal"`  // signal sent on stop (default SIGTERM)
+	StopTimeout time.Duration `yaml:"stop_timeout"` // wait this long for exit before SIGKILL (default 5s)
+
+	Logs      *LogsConfig `yaml:"logs"`       // persistent log files, overrides the top-level logs block
+	LogBuffer int         `yaml:"log_buffer"` // lines kept in memory (default: top-level log_buffer)
+
+	LogFormat LogFormat `yaml:"log_format"` // text, json, logfmt
+	LogKeys   LogKeys   `yaml:"log_keys"`   // keys holding level, message and time in structured lines
+
+	Levels        []LevelRule `yaml:"levels"`          // regex -> level rules, first match wins
+	LevelPreset   string      `yaml:"level_preset"`    // built-in rules: vite, nextjs, slog, python
+	StderrAsError *bool       `yaml:"stderr_as_error"` // classify unmatched stderr lines as errors (default true)
+
+	Multiline *MultilineConfig `yaml:"multiline"` // group stack traces into one log entry
+
+	TTY bool `yaml:"tty"` // run under a pseudo-terminal to keep colors (not on Windows)
+}
+
+// MultilineConfig groups continuation lines, such as the frames of a stack
+// trace, with the line before them into a single log entry. Use either
+// Start, or Continue and/or Indent.
+type MultilineConfig struct {
+	Start    string        `yaml:"start"`     // regex for the first line of an entry; other lines continue it
+	Continue string        `yaml:"continue"`  // regex for continuation lines
+	Indent   bool          `yaml:"indent"`    // lines starting with whitespace are continuation lines
+	MaxLines int           `yaml:"max_lines"` // split entries longer than this (default 200)
+	Timeout  time.Duration `yaml:"timeout"`   // emit an entry after this long without new lines (default 100ms)
+
+	startRe    *regexp.Regexp
+	continueRe *regexp.Regexp
+}
+
+// Continues reports whether line continues the previous entry
+func (m *MultilineConfig) Continues(line string) bool {
+	if m.startRe != nil {
+		return !m.startRe.MatchString(line)
+	}
+	if m.Indent && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+		return true
+	}
+	return m.continueRe != nil && m.continueRe.MatchString(line)
+}
+
+// LogKeys names the keys of structured log lines. Empty keys fall back to
+// common names: level/lvl/severity, msg/message, time/ts/timestamp.
+type LogKeys struct {
+	Level   string `yaml:"level"`
+	Message string `yaml:"message"`
+	Time    string `yaml:"time"`
+}
+
+// LogsConfig enables persistent log files. A service-level block overrides
+// the fields it sets on top of the top-level block.
+type LogsConfig struct {
+	Enabled  *bool         `yaml:"enabled"`   // write log files (default true when a logs block is present)
+	Dir      string        `yaml:"dir"`       // directory for log files, relative to devir.yaml (default .devir/logs)
+	MaxSize  int           `yaml:"max_size"`  // rotate when the file reaches this many MB (default 10)
+	MaxAge   time.Duration `yaml:"max_age"`   // rotate when the file is older than this (default: no limit)
+	MaxFiles int           `yaml:"max_files"` // rotated files to keep (default 5)
+	Compress *bool         `yaml:"compress"`  // gzip rotated files (default true)
+}
+
+// WatchConfig defines which files trigger a restart. Globs are relative to
+// the service dir and support ** for any number of directories; a glob
+// without a slash matches the file name in any directory.
+type WatchConfig struct {
+	Include  []string      `yaml:"include"`  // globs to watch (default: all files)
+	Exclude  []string      `yaml:"exclude"`  // globs to ignore, checked after include
+	Debounce time.Duration `yaml:"debounce"` // wait for changes to settle (default 500ms)
+}
+
+// ReadyCheck defines how to tell that a long-running service is ready.
+// Exactly one of HTTP, TCP, Log or Cmd must be set.
+type ReadyCheck struct {
+	HTTP     string        `yaml:"http"`     // URL (or path on localhost:port) to GET
+	Status   int           `yaml:"status"`   // expected HTTP status code (default 200)
+	TCP      bool          `yaml:"tcp"`      // connect to the service port
+	Log      string        `yaml:"log"`      // regex matched against the service output
+	Cmd      string        `yaml:"cmd"`      // shell command, ready when it exits 0
+	Interval time.Duration `yaml:"interval"` // time between probes (default 1s)
+	Timeout  time.Duration `yaml:"timeout"`  // mark unhealthy if not ready within this time (default 60s)
 }
 
 // IsLongRunning returns true if this service runs continuously
@@ -40,6 +162,16 @@ func (s *Service) IsLongRunning() bool {
 	return s.Type == ServiceTypeDefault || s.Type == ServiceTypeService
 }
 
+// UsesShell returns true if cmd is run through the shell
+func (s *Service) UsesShell() bool {
+	return s.Shell != nil && *s.Shell
+}
+
+// RestartEnabled returns true if the service is restarted automatically
+func (s *Service) RestartEnabled() bool {
+	return s.Restart == RestartOnFailure || s.Restart == RestartAlways
+}
+
 // GetEffectiveType returns the effective service type (handles empty default)
 func (s *Service) GetEffectiveType() ServiceType {
 	if s.Type == ServiceTypeDefault {
@@ -50,9 +182,17 @@ func (s *Service) GetEffectiveType() ServiceType {
 
 // Config represents the devir configuration
 type Config struct {
-	Services map[string]Service `yaml:"services"`
-	Defaults []string           `yaml:"defaults"`
-	RootDir  string             `yaml:"-"` // Computed from config file location
+	Services        map[string]Service `yaml:"services"`
+	Defaults        []string           `yaml:"defaults"`
+	Env             map[string]string  `yaml:"env"`               // Inherited by all services
+	Shell           bool               `yaml:"shell"`             // Default for services without shell
+	Logs            *LogsConfig        `yaml:"logs"`              // Persistent log files for all services
+	LogBuffer       int                `yaml:"log_buffer"`        // Lines kept in memory per service (default 1000)
+	Redact          []string           `yaml:"redact"`            // Regexes for secrets to hide in log output
+	NoDefaultRedact bool               `yaml:"no_default_redact"` // Disable the built-in secret detectors
+	RootDir         string             `yaml:"-"`                 // Computed from config file location
+	Path            string             `yaml:"-"`                 // Config file path as given or found
+	Hash            string             `yaml:"-"`                 // Hash of the config file contents
 }
 
 // Load loads configuration from the given path or searches for devir.yaml
@@ -77,6 +217,22 @@ func Load(path string) (*Config, error) {
 
 	// Set root dir from config file location
 	cfg.RootDir = filepath.Dir(path)
+	cfg.Path = path
+	sum := sha256.Sum256(data)
+	cfg.Hash = hex.EncodeToString(sum[:8])
+
+	for _, p := range cfg.Redact {
+		if _, err := regexp.Compile(p); err != nil {
+			return nil, fmt.Errorf("redact: %w", err)
+		}
+	}
+
+	if cfg.LogBuffer < 0 {
+		return nil, fmt.Errorf("log_buffer must not be negative")
+	}
+	if cfg.LogBuffer == 0 {
+		cfg.LogBuffer = 1000
+	}
 
 	// Set defaults if not specified
 	if len(cfg.Defaults) == 0 {
@@ -87,6 +243,10 @@ func Load(path string) (*Config, error) {
 
 	// Validate services
 	for name, svc := range cfg.Services {
+		if slices.Contains(reservedNames, name) {
+			return nil, fmt.Errorf("service %s: name is reserved for the devir %s command", name, name)
+		}
+
 		// Validate based on service type
 		switch svc.Type {
 		case ServiceTypeHTTP:
@@ -99,8 +259,8 @@ func Load(path string) (*Config, error) {
 			}
 		case ServiceTypeInterval:
 			// Interval type requires cmd and interval
-			if svc.Cmd == "" {
-				return nil, fmt.Errorf("service %s: cmd is required", name)
+			if svc.Cmd == "" && len(svc.Args) == 0 {
+				return nil, fmt.Errorf("service %s: cmd or args is required", name)
 			}
 			if svc.Interval <= 0 {
 				return nil, fmt.Errorf("service %s: interval is required for interval type", name)
@@ -110,11 +270,76 @@ func Load(path string) (*Config, error) {
 			if svc.Dir == "" && svc.Type != ServiceTypeOneshot {
 				return nil, fmt.Errorf("service %s: dir is required", name)
 			}
-			if svc.Cmd == "" {
-				return nil, fmt.Errorf("service %s: cmd is required", name)
+			if svc.Cmd == "" && len(svc.Args) == 0 {
+				return nil, fmt.Errorf("service %s: cmd or args is required", name)
+			}
+		}
+
+		if svc.Cmd != "" && len(svc.Args) > 0 {
+			return nil, fmt.Errorf("service %s: use either cmd or args, not both", name)
+		}
+
+		// Inherit the top-level shell setting
+		if svc.Shell == nil {
+			shell := cfg.Shell
+			svc.Shell = &shell
+		}
+
+		if svc.Ready != nil {
+			if err := validateReadyCheck(&svc); err != nil {
+				return nil, fmt.Errorf("service %s: %w", name, err)
+			}
+		}
+
+		if svc.Watch != nil {
+			if err := validateWatch(&svc); err != nil {
+				return nil, fmt.Errorf("service %s: %w", name, err)
+			}
+		}
+
+		if err := validateStop(&svc); err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+
+		if err := validateRestart(&svc); err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+
+		logs, err := resolveLogs(cfg.Logs, svc.Logs)
+		if err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+		svc.Logs = logs
+
+		switch svc.LogFormat {
+		case "":
+			svc.LogFormat = LogFormatText
+		case LogFormatText, LogFormatJSON, LogFormatLogfmt:
+		default:
+			return nil, fmt.Errorf("service %s: unknown log_format %q (use text, json or logfmt)", name, svc.LogFormat)
+		}
+
+		if svc.TTY && svc.Type != ServiceTypeDefault && svc.Type != ServiceTypeService && svc.Type != ServiceTypeOneshot {
+			return nil, fmt.Errorf("service %s: tty is only supported for long-running and oneshot services", name)
+		}
+
+		if svc.Multiline != nil {
+			if err := validateMultiline(svc.Multiline); err != nil {
+				return nil, fmt.Errorf("service %s: %w", name, err)
 			}
 		}
 
+		if err := validateLevels(&svc); err != nil {
+			return nil, fmt.Errorf("service %s: %w", name, err)
+		}
+
+		if svc.LogBuffer < 0 {
+			return nil, fmt.Errorf("service %s: log_buffer must not be negative", name)
+		}
+		if svc.LogBuffer == 0 {
+			svc.LogBuffer = cfg.LogBuffer
+		}
+
 		// Set default color
 		if svc.Color == "" {
 			svc.Color = "white"
@@ -128,9 +353,214 @@ func Load(path string) (*Config, error) {
 		cfg.Services[name] = svc
 	}
 
+	if err := validateDependencies(cfg.Services); err != nil {
+		return nil, err
+	}
+
 	return &cfg, nil
 }
 
+// validateReadyCheck validates a readiness probe and fills in its defaults
+func validateReadyCheck(svc *Service) error {
+	if !svc.IsLongRunning() {
+		return fmt.Errorf("ready is only supported for long-running services")
+	}
+
+	rc := svc.Ready
+	probes := 0
+	for _, set := range []bool{rc.HTTP != "", rc.TCP, rc.Log != "", rc.Cmd != ""} {
+		if set {
+			probes++
+		}
+	}
+	if probes != 1 {
+		return fmt.Errorf("ready requires exactly one of http, tcp, log or cmd")
+	}
+
+	if rc.TCP && svc.Port <= 0 {
+		return fmt.Errorf("ready.tcp requires port")
+	}
+	if strings.HasPrefix(rc.HTTP, "/") && svc.Port <= 0 {
+		return fmt.Errorf("ready.http path requires port")
+	}
+	if rc.Log != "" {
+		if _, err := regexp.Compile(rc.Log); err != nil {
+			return fmt.Errorf("ready.log: %w", err)
+		}
+	}
+
+	if rc.Status == 0 {
+		rc.Status = 200
+	}
+	if rc.Interval <= 0 {
+		rc.Interval = time.Second
+	}
+	if rc.Timeout <= 0 {
+		rc.Timeout = 60 * time.Second
+	}
+	return nil
+}
+
+// reservedNames are the devir subcommands, which can't be told apart from
+// service names on the command line
+var reservedNames = []string{"init", "up", "down", "attach", "reload", "daemon"}
+
+// stopSignals lists the signals accepted for stop_signal
+var stopSignals = []string{"SIGTERM", "SIGINT", "SIGQUIT", "SIGHUP", "SIGUSR1", "SIGUSR2", "SIGKILL"}
+
+// validateStop normalizes stop_signal (e.g. "int" -> "SIGINT") and fills in defaults
+func validateStop(svc *Service) error {
+	if svc.StopSignal == "" {
+		svc.StopSignal = "SIGTERM"
+	}
+	sig := strings.ToUpper(svc.StopSignal)
+	if !strings.HasPrefix(sig, "SIG") {
+		sig = "SIG" + sig
+	}
+	if !slices.Contains(stopSignals, sig) {
+		return fmt.Errorf("unsupported stop_signal %q (use one of %s)", svc.StopSignal, strings.Join(stopSignals, ", "))
+	}
+	svc.StopSignal = sig
+
+	if svc.StopTimeout <= 0 {
+		svc.StopTimeout = 5 * time.Second
+	}
+	return nil
+}
+
+// resolveLogs merges a service's logs block over the top-level one and fills
+// in defaults. It returns nil if log files are disabled for the service.
+func resolveLogs(global, local *LogsConfig) (*LogsConfig, error) {
+	if global == nil && local == nil {
+		return nil, nil
+	}
+
+	lc := LogsConfig{}
+	for _, src := range []*LogsConfig{global, local} {
+		if src == nil {
+			continue
+		}
+		if src.Enabled != nil {
+			lc.Enabled = src.Enabled
+		}
+		if src.Dir != "" {
+			lc.Dir = src.Dir
+		}
+		if src.MaxSize != 0 {
+			lc.MaxSize = src.MaxSize
+		}
+		if src.MaxAge != 0 {
+			lc.MaxAge = src.MaxAge
+		}
+		if src.MaxFiles != 0 {
+			lc.MaxFiles = src.MaxFiles
+		}
+		if src.Compress != nil {
+			lc.Compress = src.Compress
+		}
+	}
+
+	if lc.Enabled != nil && !*lc.Enabled {
+		return nil, nil
+	}
+	if lc.MaxSize < 0 || lc.MaxAge < 0 || lc.MaxFiles < 0 {
+		return nil, fmt.Errorf("logs: max_size, max_age and max_files must not be negative")
+	}
+
+	if lc.Dir == "" {
+		lc.Dir = filepath.Join(".devir", "logs")
+	}
+	if lc.MaxSize == 0 {
+		lc.MaxSize = 10
+	}
+	if lc.MaxFiles == 0 {
+		lc.MaxFiles = 5
+	}
+	if lc.Compress == nil {
+		compress := true
+		lc.Compress = &compress
+	}
+	return &lc, nil
+}
+
+// validateRestart validates the restart policy and fills in backoff defaults
+func validateRestart(svc *Service) error {
+	switch svc.Restart {
+	case "", RestartNo:
+		svc.Restart = RestartNo
+		return nil
+	case RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unknown restart policy %q (use no, on-failure or always)", svc.Restart)
+	}
+
+	if !svc.IsLongRunning() {
+		return fmt.Errorf("restart is only supported for long-running services")
+	}
+	if svc.MaxRestarts < 0 {
+		return fmt.Errorf("max_restarts must not be negative")
+	}
+
+	if svc.BackoffBase <= 0 {
+		svc.BackoffBase = time.Second
+	}
+	if svc.BackoffMax <= 0 {
+		svc.BackoffMax = 30 * time.Second
+	}
+	if svc.BackoffMax < svc.BackoffBase {
+		svc.BackoffMax = svc.BackoffBase
+	}
+	return nil
+}
+
+// validateWatch validates watch globs and fills in the debounce default
+func validateWatch(svc *Service) error {
+	if svc.Type == ServiceTypeHTTP {
+		return fmt.Errorf("watch is not supported for http services")
+	}
+
+	for _, pattern := range append(append([]string{}, svc.Watch.Include...), svc.Watch.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("watch: invalid glob %q", pattern)
+		}
+	}
+
+	if svc.Watch.Debounce <= 0 {
+		svc.Watch.Debounce = 500 * time.Millisecond
+	}
+	return nil
+}
+
+// validateMultiline compiles the multiline regexes and fills in defaults
+func validateMultiline(m *MultilineConfig) error {
+	if m.Start != "" && (m.Continue != "" || m.Indent) {
+		return fmt.Errorf("multiline: use either start, or continue and indent")
+	}
+	if m.Start == "" && m.Continue == "" && !m.Indent {
+		return fmt.Errorf("multiline requires start, continue or indent")
+	}
+
+	var err error
+	if m.Start != "" {
+		if m.startRe, err = regexp.Compile(m.Start); err != nil {
+			return fmt.Errorf("multiline.start: %w", err)
+		}
+	}
+	if m.Continue != "" {
+		if m.continueRe, err = regexp.Compile(m.Continue); err != nil {
+			return fmt.Errorf("multiline.continue: %w", err)
+		}
+	}
+
+	if m.MaxLines <= 0 {
+		m.MaxLines = 200
+	}
+	if m.Timeout <= 0 {
+		m.Timeout = 100 * time.Millisecond
+	}
+	return nil
+}
+
 // FindConfigFile looks for devir.yaml in current dir and parents
 func FindConfigFile() string {
 	dir, _ := os.Getwd()
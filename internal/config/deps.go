@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// validateDependencies checks that every depends_on entry refers to a known
+// service and that the dependency graph has no cycles
+func validateDependencies(services map[string]Service) error {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, dep := range services[name].DependsOn {
+			if dep == name {
+				return fmt.Errorf("service %s: cannot depend on itself", name)
+			}
+			if _, ok := services[dep]; !ok {
+				return fmt.Errorf("service %s: unknown dependency %s", name, dep)
+			}
+		}
+	}
+
+	// Depth-first search with three colors: unvisited, in progress, done
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	marks := make(map[string]int, len(services))
+	var path []string
+
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch marks[name] {
+		case visiting:
+			// Report the cycle starting from the first occurrence of name
+			start := 0
+			for i, n := range path {
+				if n == name {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), name)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		case visited:
+			return nil
+		}
+
+		marks[name] = visiting
+		path = append(path, name)
+		for _, dep := range services[name].DependsOn {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		marks[name] = visited
+		return nil
+	}
+
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ResolveDependencies returns the given services together with all of their
+// transitive dependencies, in topological order (dependencies first).
+// Unknown service names are kept in place so callers can report them.
+func (c *Config) ResolveDependencies(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	var order []string
+
+	var visit func(name string)
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		for _, dep := range c.Services[name].DependsOn {
+			visit(dep)
+		}
+		order = append(order, name)
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+	return order
+}
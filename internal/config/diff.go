@@ -0,0 +1,57 @@
+package config
+
+import (
+	"bytes"
+	"maps"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Diff lists the services that differ between two configs
+type Diff struct {
+	Added   []string // only in the new config
+	Removed []string // only in the old config
+	Changed []string // in both, with a different definition
+}
+
+// Empty reports whether no service differs
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// DiffServices compares the services of two loaded configs. A change of the
+// top-level env counts as a change of every service, since they inherit it.
+func DiffServices(old, new *Config) Diff {
+	var d Diff
+	envChanged := !maps.Equal(old.Env, new.Env)
+
+	for name, svc := range new.Services {
+		prev, ok := old.Services[name]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, name)
+		case envChanged || !sameService(prev, svc):
+			d.Changed = append(d.Changed, name)
+		}
+	}
+	for name := range old.Services {
+		if _, ok := new.Services[name]; !ok {
+			d.Removed = append(d.Removed, name)
+		}
+	}
+
+	sort.Strings(d.Added)
+	sort.Strings(d.Removed)
+	sort.Strings(d.Changed)
+	return d
+}
+
+// sameService compares two service definitions after Load has filled in
+// their defaults. Compiled patterns are unexported and skipped by yaml, so
+// the definitions are compared as written.
+func sameService(a, b Service) bool {
+	ya, errA := yaml.Marshal(a)
+	yb, errB := yaml.Marshal(b)
+	return errA == nil && errB == nil && bytes.Equal(ya, yb)
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultEnv is added to every command unless no_default_env is set, so that
+// tools produce plain output that is easy to read in the log view
+var defaultEnv = []string{
+	"CI=true",
+	"TERM=dumb",
+	"NO_COLOR=1",
+	"FORCE_COLOR=0",
+}
+
+// ttyEnv replaces defaultEnv for tty services, which keep their colors
+var ttyEnv = []string{
+	"TERM=xterm-256color",
+	"FORCE_COLOR=1",
+}
+
+// ResolveEnv returns the environment for a service's commands. Later sources
+// override earlier ones: the devir process environment, the default plain
+// output variables (or color ones for tty services), top-level env, env_file
+// entries in order, service env.
+// Values may reference variables defined before them as ${NAME}; $$ is a
+// literal $.
+func (c *Config) ResolveEnv(svc Service) (map[string]string, error) {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			env[k] = v
+		}
+	}
+	if !svc.NoDefaultEnv {
+		defaults := defaultEnv
+		if svc.TTY {
+			defaults = ttyEnv
+		}
+		for _, kv := range defaults {
+			k, v, _ := strings.Cut(kv, "=")
+			env[k] = v
+		}
+	}
+
+	setAll := func(vars map[string]string) {
+		// Sort so references between entries of the same map are deterministic
+		keys := make([]string, 0, len(vars))
+		for k := range vars {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			env[k] = ExpandEnv(vars[k], env)
+		}
+	}
+
+	setAll(c.Env)
+
+	for _, file := range svc.EnvFile {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(c.RootDir, svc.Dir, path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading env_file: %w", err)
+		}
+		pairs, err := parseDotenv(data)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", file, err)
+		}
+		for _, p := range pairs {
+			if p.literal {
+				env[p.key] = p.value
+			} else {
+				env[p.key] = ExpandEnv(p.value, env)
+			}
+		}
+	}
+
+	setAll(svc.Env)
+
+	return env, nil
+}
+
+// EnvList converts an environment map to the KEY=VALUE form used by exec.Cmd
+func EnvList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// envValueRefPattern matches $$, ${NAME} and $NAME in env values
+var envValueRefPattern = regexp.MustCompile(`\$(?:\$|\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))`)
+
+// ExpandEnv replaces ${NAME} and $NAME references to variables defined in
+// env and $$ with a single $. Anything else, such as a $ in a password, is
+// left as written.
+func ExpandEnv(s string, env map[string]string) string {
+	return envValueRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		name := strings.Trim(ref[1:], "{}")
+		if value, ok := env[name]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// envRefPattern matches a ${NAME} reference
+var envRefPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandDefined replaces ${NAME} references to variables defined in env and
+// leaves everything else as written, so text with a literal $ is unchanged
+func ExpandDefined(s string, env map[string]string) string {
+	return envRefPattern.ReplaceAllStringFunc(s, func(ref string) string {
+		if value, ok := env[ref[2:len(ref)-1]]; ok {
+			return value
+		}
+		return ref
+	})
+}
+
+// envPair is a single entry of a .env file
+type envPair struct {
+	key     string
+	value   string
+	literal bool // single quoted, not subject to ${NAME} expansion
+}
+
+// parseDotenv parses a .env file into ordered key/value pairs. Supports
+// comments, an optional "export " prefix, and single or double quoted values.
+func parseDotenv(data []byte) ([]envPair, error) {
+	var pairs []envPair
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected KEY=VALUE", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if key == "" {
+			return nil, fmt.Errorf("line %d: empty key", lineNo)
+		}
+		value = strings.TrimSpace(value)
+		literal := false
+
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			value = value[1 : len(value)-1]
+			value = strings.NewReplacer(`\n`, "\n", `\"`, `"`, `\\`, `\`).Replace(value)
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+			literal = true
+		default:
+			// Strip inline comments from unquoted values
+			if i := strings.Index(value, " #"); i >= 0 {
+				value = strings.TrimSpace(value[:i])
+			}
+		}
+
+		pairs = append(pairs, envPair{key: key, value: value, literal: literal})
+	}
+
+	return pairs, scanner.Err()
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// LogLevels are the levels a log line can be classified as
+var LogLevels = []string{"debug", "info", "warn", "error"}
+
+// LevelRule classifies output lines matching a regex as the given level
+type LevelRule struct {
+	Match string `yaml:"match"` // regex matched against the line
+	Level string `yaml:"level"` // debug, info, warn, error
+
+	re *regexp.Regexp
+}
+
+// Matches reports whether the rule applies to text
+func (r LevelRule) Matches(text string) bool {
+	return r.re != nil && r.re.MatchString(text)
+}
+
+// levelPreset is a built-in set of level rules for a common tool
+type levelPreset struct {
+	rules []LevelRule
+	// stderrAsError is the stderr_as_error default for tools that log
+	// everything to stderr
+	stderrAsError bool
+}
+
+var levelPresets = map[string]levelPreset{
+	"vite": {
+		stderrAsError: true,
+		rules: []LevelRule{
+			{Match: `\b0 errors?\b`, Level: "info"},
+			{Match: `(?i)internal server error|\berror\b|✘`, Level: "error"},
+			{Match: `(?i)\bwarn(ing)?\b|▲`, Level: "warn"},
+			{Match: `(?i)\b(hmr update|page reload|ready in|local:|network:)`, Level: "info"},
+		},
+	},
+	"nextjs": {
+		stderrAsError: true,
+		rules: []LevelRule{
+			{Match: `^\s*(⨯|error\s+-)`, Level: "error"},
+			{Match: `^\s*(⚠|warn\s+-)`, Level: "warn"},
+			{Match: `^\s*(✓|○|ƒ|▲|info\s+-|ready\s+-|event\s+-|wait\s+-)`, Level: "info"},
+		},
+	},
+	"slog": {
+		// The log package writes to stderr by default
+		stderrAsError: false,
+		rules: []LevelRule{
+			{Match: `\blevel=(ERROR|error)\b|^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d(\.\d+)? ERROR\b|^panic: `, Level: "error"},
+			{Match: `\blevel=(WARN|warn)\b|^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d(\.\d+)? WARN\b`, Level: "warn"},
+			{Match: `\blevel=(DEBUG|debug)\b|^\d{4}/\d\d/\d\d \d\d:\d\d:\d\d(\.\d+)? DEBUG\b`, Level: "debug"},
+			{Match: `.`, Level: "info"},
+		},
+	},
+	"python": {
+		// logging.basicConfig writes to stderr
+		stderrAsError: false,
+		rules: []LevelRule{
+			{Match: `^(CRITICAL|ERROR):|\b(CRITICAL|ERROR)\s*[-:|]|^Traceback \(most recent call last\)`, Level: "error"},
+			{Match: `^WARNING:|\bWARNING\s*[-:|]`, Level: "warn"},
+			{Match: `^DEBUG:|\bDEBUG\s*[-:|]`, Level: "debug"},
+			{Match: `^INFO:|\bINFO\s*[-:|]`, Level: "info"},
+		},
+	},
+}
+
+// validateLevels compiles the service's level rules and appends the rules of
+// its preset, so that Levels holds the complete ordered rule list
+func validateLevels(svc *Service) error {
+	for i := range svc.Levels {
+		if err := compileLevelRule(&svc.Levels[i]); err != nil {
+			return fmt.Errorf("levels[%d]: %w", i, err)
+		}
+	}
+
+	stderrAsError := true
+	if svc.LevelPreset != "" {
+		preset, ok := levelPresets[svc.LevelPreset]
+		if !ok {
+			names := make([]string, 0, len(levelPresets))
+			for name := range levelPresets {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return fmt.Errorf("unknown level_preset %q (use one of %s)", svc.LevelPreset, strings.Join(names, ", "))
+		}
+		for _, rule := range preset.rules {
+			if err := compileLevelRule(&rule); err != nil {
+				return fmt.Errorf("level_preset %s: %w", svc.LevelPreset, err)
+			}
+			svc.Levels = append(svc.Levels, rule)
+		}
+		stderrAsError = preset.stderrAsError
+	}
+
+	if svc.StderrAsError == nil {
+		svc.StderrAsError = &stderrAsError
+	}
+	return nil
+}
+
+func compileLevelRule(rule *LevelRule) error {
+	if !slices.Contains(LogLevels, rule.Level) {
+		return fmt.Errorf("unknown level %q (use one of %s)", rule.Level, strings.Join(LogLevels, ", "))
+	}
+	re, err := regexp.Compile(rule.Match)
+	if err != nil {
+		return err
+	}
+	rule.re = re
+	return nil
+}
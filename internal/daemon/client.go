@@ -5,8 +5,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"devir/internal/config"
 )
 
 // Client connects to a daemon
@@ -15,40 +20,103 @@ type Client struct {
 	sendCh    chan Message
 	recvCh    chan Message
 	closeCh   chan struct{}
+	readDone  chan struct{} // Closed when the daemon ends the connection
 	wg        sync.WaitGroup
 	mu        sync.Mutex
 	closed    bool
 	handlers  map[string]func(Message)
 	handlerMu sync.RWMutex
+	nextID    atomic.Uint64
+	pending   map[string]chan Message // Sync requests waiting for their response, by ID
+	pendingMu sync.Mutex
+	daemon    Hello // The daemon's handshake
 }
 
-// Connect connects to an existing daemon
+// helloTimeout is how long Connect waits for the daemon's hello
+const helloTimeout = 2 * time.Second
+
+// Connect connects to an existing daemon. It fails if the daemon speaks a
+// different protocol version or predates the handshake; other differences
+// are reported by Mismatches.
 func Connect(socketPath string) (*Client, error) {
 	conn, err := net.DialTimeout("unix", socketPath, 5*time.Second)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to daemon: %w", err)
 	}
 
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+
+	hello, err := readHello(conn, scanner)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
 	c := &Client{
 		conn:     conn,
 		sendCh:   make(chan Message, 100),
 		recvCh:   make(chan Message, 100),
 		closeCh:  make(chan struct{}),
+		readDone: make(chan struct{}),
 		handlers: make(map[string]func(Message)),
+		pending:  make(map[string]chan Message),
+		daemon:   hello,
 	}
 
 	c.wg.Add(2)
-	go c.readLoop()
+	go c.readLoop(scanner)
 	go c.writeLoop()
 
 	return c, nil
 }
 
-func (c *Client) readLoop() {
-	defer c.wg.Done()
+// readHello reads the daemon's hello, which is the first message it sends
+func readHello(conn net.Conn, scanner *bufio.Scanner) (Hello, error) {
+	_ = conn.SetReadDeadline(time.Now().Add(helloTimeout))
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
 
-	scanner := bufio.NewScanner(c.conn)
-	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	var msg Message
+	if !scanner.Scan() || json.Unmarshal(scanner.Bytes(), &msg) != nil || msg.Type != MsgHello {
+		return Hello{}, fmt.Errorf("daemon did not identify itself; it is probably from an older devir, stop it and try again")
+	}
+	hello, err := ParsePayload[Hello](msg)
+	if err != nil {
+		return Hello{}, fmt.Errorf("invalid hello from daemon: %w", err)
+	}
+	if hello.ProtocolVersion != ProtocolVersion {
+		return Hello{}, fmt.Errorf("daemon (devir %s) speaks protocol version %d, this devir speaks %d; stop it and try again",
+			hello.Version, hello.ProtocolVersion, ProtocolVersion)
+	}
+	return hello, nil
+}
+
+// Daemon returns what the daemon reported about itself when connecting
+func (c *Client) Daemon() Hello {
+	return c.daemon
+}
+
+// Mismatches describes how the daemon differs from this devir and the given
+// config, for warning the user. Empty if they match.
+func (c *Client) Mismatches(cfg *config.Config) []string {
+	var diffs []string
+	if c.daemon.Version != Version {
+		diffs = append(diffs, fmt.Sprintf("the daemon runs devir %s, this is devir %s", c.daemon.Version, Version))
+	}
+	if cfg != nil && c.daemon.ConfigHash != "" && c.daemon.ConfigHash != cfg.Hash {
+		diffs = append(diffs, "the daemon was started with a different version of devir.yaml")
+	}
+	return diffs
+}
+
+// HasCapability reports whether the daemon supports an optional feature
+func (c *Client) HasCapability(name string) bool {
+	return slices.Contains(c.daemon.Capabilities, name)
+}
+
+func (c *Client) readLoop(scanner *bufio.Scanner) {
+	defer c.wg.Done()
+	defer close(c.readDone)
 
 	for scanner.Scan() {
 		var msg Message
@@ -56,6 +124,18 @@ func (c *Client) readLoop() {
 			continue
 		}
 
+		// Responses to sync requests go to the waiting caller
+		if msg.ID != "" {
+			c.pendingMu.Lock()
+			ch, ok := c.pending[msg.ID]
+			delete(c.pending, msg.ID)
+			c.pendingMu.Unlock()
+			if ok {
+				ch <- msg
+				continue
+			}
+		}
+
 		// Check for registered handler
 		c.handlerMu.RLock()
 		handler, ok := c.handlers[msg.Type]
@@ -202,7 +282,113 @@ func (c *Client) ClearLogs(service string) error {
 	return c.Send(msg)
 }
 
-// WaitForResponse waits for a specific response type
+// Stdin sends input to a running service
+func (c *Client) Stdin(service string, data []byte) error {
+	msg, err := NewMessage(MsgStdin, StdinRequest{Service: service, Data: string(data)})
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+// Subscribe limits the log entries the daemon sends to this client. Empty
+// arguments don't restrict anything, so Subscribe(nil, "", "") receives
+// everything again.
+func (c *Client) Subscribe(services []string, level, match string, timeout time.Duration) error {
+	_, err := c.subscribe(SubscribeRequest{
+		Services: services,
+		Level:    level,
+		Match:    match,
+	}, timeout)
+	return err
+}
+
+// Resume subscribes like Subscribe and returns the stored entries with a
+// seq after the given one, which is the last one the client has. Entries
+// that arrive later follow without gaps or duplicates.
+func (c *Client) Resume(services []string, level, match string, after uint64, timeout time.Duration) ([]LogEntryData, error) {
+	resp, err := c.subscribe(SubscribeRequest{
+		Services: services,
+		Level:    level,
+		Match:    match,
+		After:    &after,
+	}, timeout)
+	return resp.Logs, err
+}
+
+func (c *Client) subscribe(req SubscribeRequest, timeout time.Duration) (SubscribedResponse, error) {
+	msg, err := NewMessage(MsgSubscribe, req)
+	if err != nil {
+		return SubscribedResponse{}, err
+	}
+
+	msg, err = c.request(msg, MsgSubscribed, timeout)
+	if err != nil {
+		return SubscribedResponse{}, err
+	}
+	return ParsePayload[SubscribedResponse](msg)
+}
+
+// Shutdown stops the services and asks a detached daemon to exit
+func (c *Client) Shutdown(timeout time.Duration) error {
+	msg, _ := NewMessage(MsgShutdown, struct{}{})
+	_, err := c.request(msg, MsgShuttingDown, timeout)
+	return err
+}
+
+// Reload asks the daemon to reload devir.yaml and returns what changed
+func (c *Client) Reload(timeout time.Duration) (ConfigChange, error) {
+	msg, _ := NewMessage(MsgReload, struct{}{})
+	msg, err := c.request(msg, MsgReloaded, timeout)
+	if err != nil {
+		return ConfigChange{}, err
+	}
+	return ParsePayload[ConfigChange](msg)
+}
+
+// request sends msg with a new ID and waits for the response carrying that
+// ID, so concurrent requests never receive each other's responses. An error
+// response is returned as an error.
+func (c *Client) request(msg Message, respType string, timeout time.Duration) (Message, error) {
+	msg.ID = strconv.FormatUint(c.nextID.Add(1), 10)
+	ch := make(chan Message, 1)
+
+	c.pendingMu.Lock()
+	c.pending[msg.ID] = ch
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, msg.ID)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.Send(msg); err != nil {
+		return Message{}, err
+	}
+
+	select {
+	case resp := <-ch:
+		switch resp.Type {
+		case respType:
+			return resp, nil
+		case MsgError:
+			errResp, _ := ParsePayload[ErrorResponse](resp)
+			return resp, fmt.Errorf("daemon error: %s", errResp.Error)
+		default:
+			return resp, fmt.Errorf("unexpected response %s to %s", resp.Type, msg.Type)
+		}
+	case <-c.closeCh:
+		return Message{}, fmt.Errorf("client closed")
+	case <-c.readDone:
+		return Message{}, fmt.Errorf("connection to daemon lost")
+	case <-time.After(timeout):
+		return Message{}, fmt.Errorf("timeout waiting for %s", respType)
+	}
+}
+
+// WaitForResponse waits for the next message of a specific response type.
+// Only responses to requests sent without an ID arrive here; the Sync
+// helpers match responses by ID instead.
 func (c *Client) WaitForResponse(msgType string, timeout time.Duration) (Message, error) {
 	deadline := time.Now().Add(timeout)
 
@@ -223,31 +409,39 @@ func (c *Client) WaitForResponse(msgType string, timeout time.Duration) (Message
 }
 
 // StartAndWait starts services and waits for confirmation
-func (c *Client) StartAndWait(services []string, killPorts bool, timeout time.Duration) ([]string, error) {
-	if err := c.Start(services, killPorts); err != nil {
-		return nil, err
+func (c *Client) StartAndWait(services []string, killPorts bool, timeout time.Duration) (StartedResponse, error) {
+	msg, err := NewMessage(MsgStart, StartRequest{
+		Services:  services,
+		KillPorts: killPorts,
+	})
+	if err != nil {
+		return StartedResponse{}, err
 	}
 
-	msg, err := c.WaitForResponse(MsgStarted, timeout)
+	msg, err = c.request(msg, MsgStarted, timeout)
 	if err != nil {
-		return nil, err
+		return StartedResponse{}, err
 	}
 
-	resp, err := ParsePayload[StartedResponse](msg)
+	return ParsePayload[StartedResponse](msg)
+}
+
+// StopSync stops services (all if none are given) and waits until they
+// have stopped
+func (c *Client) StopSync(services []string, timeout time.Duration) error {
+	msg, err := NewMessage(MsgStop, StopRequest{Services: services})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return resp.Services, nil
+	_, err = c.request(msg, MsgStopped, timeout)
+	return err
 }
 
 // StatusSync gets status synchronously
 func (c *Client) StatusSync(timeout time.Duration) ([]ServiceStatus, error) {
-	if err := c.Status(); err != nil {
-		return nil, err
-	}
-
-	msg, err := c.WaitForResponse(MsgStatusResponse, timeout)
+	msg, _ := NewMessage(MsgStatus, struct{}{})
+	msg, err := c.request(msg, MsgStatusResponse, timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -262,30 +456,30 @@ func (c *Client) StatusSync(timeout time.Duration) ([]ServiceStatus, error) {
 
 // LogsSync gets logs synchronously
 func (c *Client) LogsSync(service string, lines int, timeout time.Duration) ([]LogEntryData, error) {
-	if err := c.Logs(service, lines); err != nil {
-		return nil, err
-	}
+	resp, err := c.QueryLogs(LogsRequest{Service: service, Lines: lines}, timeout)
+	return resp.Logs, err
+}
 
-	msg, err := c.WaitForResponse(MsgLogsResponse, timeout)
+// QueryLogs gets the logs selected by a request. Daemons without the
+// "log_query" capability ignore everything but Service and Lines.
+func (c *Client) QueryLogs(req LogsRequest, timeout time.Duration) (LogsResponse, error) {
+	msg, err := NewMessage(MsgLogs, req)
 	if err != nil {
-		return nil, err
+		return LogsResponse{}, err
 	}
 
-	resp, err := ParsePayload[LogsResponse](msg)
+	msg, err = c.request(msg, MsgLogsResponse, timeout)
 	if err != nil {
-		return nil, err
+		return LogsResponse{}, err
 	}
 
-	return resp.Logs, nil
+	return ParsePayload[LogsResponse](msg)
 }
 
 // CheckPortsSync checks ports synchronously
 func (c *Client) CheckPortsSync(timeout time.Duration) (PortsResponse, error) {
-	if err := c.CheckPorts(); err != nil {
-		return PortsResponse{}, err
-	}
-
-	msg, err := c.WaitForResponse(MsgPortsResponse, timeout)
+	msg, _ := NewMessage(MsgCheckPorts, struct{}{})
+	msg, err := c.request(msg, MsgPortsResponse, timeout)
 	if err != nil {
 		return PortsResponse{}, err
 	}
@@ -295,11 +489,12 @@ func (c *Client) CheckPortsSync(timeout time.Duration) (PortsResponse, error) {
 
 // KillPortsSync kills ports synchronously
 func (c *Client) KillPortsSync(ports []int, timeout time.Duration) (KillPortsResponse, error) {
-	if err := c.KillPorts(ports); err != nil {
+	msg, err := NewMessage(MsgKillPorts, KillPortsRequest{Ports: ports})
+	if err != nil {
 		return KillPortsResponse{}, err
 	}
 
-	msg, err := c.WaitForResponse(MsgKillResponse, timeout)
+	msg, err = c.request(msg, MsgKillResponse, timeout)
 	if err != nil {
 		return KillPortsResponse{}, err
 	}
@@ -309,10 +504,11 @@ func (c *Client) KillPortsSync(ports []int, timeout time.Duration) (KillPortsRes
 
 // ClearLogsSync clears logs synchronously
 func (c *Client) ClearLogsSync(service string, timeout time.Duration) error {
-	if err := c.ClearLogs(service); err != nil {
+	msg, err := NewMessage(MsgClearLogs, ClearLogsRequest{Service: service})
+	if err != nil {
 		return err
 	}
 
-	_, err := c.WaitForResponse(MsgLogsCleared, timeout)
+	_, err = c.request(msg, MsgLogsCleared, timeout)
 	return err
 }
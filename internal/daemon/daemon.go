@@ -7,8 +7,10 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"devir/internal/config"
@@ -55,10 +57,14 @@ func Exists(socketPath string) bool {
 	return true
 }
 
+// clientDrainTimeout limits how long Stop waits to write queued messages
+const clientDrainTimeout = time.Second
+
 // Daemon manages services and client connections
 type Daemon struct {
 	config     *config.Config
-	runner     *runner.Runner
+	configMu   sync.RWMutex
+	runner     atomic.Pointer[runner.Runner] // nil until services are first started
 	listener   net.Listener
 	clients    map[*clientConn]bool
 	clientsMu  sync.RWMutex
@@ -67,12 +73,19 @@ type Daemon struct {
 	wg         sync.WaitGroup
 	wsServer   *WSServer
 	wsPort     int
+
+	statusCheck chan struct{} // Wakes the status watcher
+	startMu     sync.Mutex    // Serializes start requests
+
+	shutdownCh   chan struct{} // Closed on a shutdown request, nil if not accepted
+	shutdownOnce sync.Once
 }
 
 type clientConn struct {
 	conn   net.Conn
 	sendCh chan Message
 	daemon *Daemon
+	sub    subscription
 }
 
 // New creates a new daemon
@@ -83,11 +96,12 @@ func New(cfg *config.Config, socketPath string) *Daemon {
 // NewWithWSPort creates a new daemon with custom WebSocket port
 func NewWithWSPort(cfg *config.Config, socketPath string, wsPort int) *Daemon {
 	return &Daemon{
-		config:     cfg,
-		clients:    make(map[*clientConn]bool),
-		socketPath: socketPath,
-		stopCh:     make(chan struct{}),
-		wsPort:     wsPort,
+		config:      cfg,
+		clients:     make(map[*clientConn]bool),
+		socketPath:  socketPath,
+		stopCh:      make(chan struct{}),
+		wsPort:      wsPort,
+		statusCheck: make(chan struct{}, 1),
 	}
 }
 
@@ -112,8 +126,10 @@ func (d *Daemon) Start() error {
 	}
 
 	// Accept connections
-	d.wg.Add(1)
+	d.wg.Add(3)
 	go d.acceptLoop()
+	go d.watchStatus()
+	go d.watchConfig()
 
 	return nil
 }
@@ -129,12 +145,21 @@ func (d *Daemon) RunEmbedded() (*Client, error) {
 	return Connect(d.socketPath)
 }
 
+// AcceptShutdown lets clients stop the daemon with a shutdown message and
+// returns a channel that is closed when one does. Call it before Start.
+// Only a daemon running in its own process (devir up) accepts shutdown; an
+// embedded daemon belongs to the TUI or MCP session that started it.
+func (d *Daemon) AcceptShutdown() <-chan struct{} {
+	d.shutdownCh = make(chan struct{})
+	return d.shutdownCh
+}
+
 // Stop stops the daemon
 func (d *Daemon) Stop() {
 	close(d.stopCh)
 
-	if d.runner != nil {
-		d.runner.Stop()
+	if r := d.GetRunner(); r != nil {
+		r.Stop()
 	}
 
 	if d.wsServer != nil {
@@ -145,9 +170,12 @@ func (d *Daemon) Stop() {
 		_ = d.listener.Close()
 	}
 
+	// End each connection after the messages queued for it, such as the
+	// reply to a shutdown request, are written
 	d.clientsMu.Lock()
 	for c := range d.clients {
-		_ = c.conn.Close()
+		_ = c.conn.SetWriteDeadline(time.Now().Add(clientDrainTimeout))
+		_ = c.conn.SetReadDeadline(time.Now())
 	}
 	d.clientsMu.Unlock()
 
@@ -175,6 +203,10 @@ func (d *Daemon) acceptLoop() {
 			daemon: d,
 		}
 
+		// Introduce ourselves first, so the client can check compatibility
+		hello, _ := NewMessage(MsgHello, newHello(d.GetConfig().Hash))
+		client.send(hello)
+
 		d.clientsMu.Lock()
 		d.clients[client] = true
 		d.clientsMu.Unlock()
@@ -203,6 +235,7 @@ func (c *clientConn) readLoop() {
 
 func (c *clientConn) writeLoop() {
 	defer c.daemon.wg.Done()
+	defer func() { _ = c.conn.Close() }()
 
 	encoder := json.NewEncoder(c.conn)
 	for msg := range c.sendCh {
@@ -216,8 +249,8 @@ func (c *clientConn) cleanup() {
 	c.daemon.clientsMu.Lock()
 	delete(c.daemon.clients, c)
 	c.daemon.clientsMu.Unlock()
+	// writeLoop closes the connection once the queue is written
 	close(c.sendCh)
-	_ = c.conn.Close()
 }
 
 func (c *clientConn) send(msg Message) {
@@ -228,6 +261,12 @@ func (c *clientConn) send(msg Message) {
 	}
 }
 
+// reply sends the response to a request, tagged with the request's ID
+func (c *clientConn) reply(req Message, resp Message) {
+	resp.ID = req.ID
+	c.send(resp)
+}
+
 func (d *Daemon) broadcast(msg Message) {
 	d.clientsMu.RLock()
 	defer d.clientsMu.RUnlock()
@@ -237,183 +276,206 @@ func (d *Daemon) broadcast(msg Message) {
 	}
 }
 
+// broadcastLog sends a log entry to the clients subscribed to it
+func (d *Daemon) broadcastLog(entry LogEntryData) {
+	msg, _ := NewMessage(MsgLogEntry, entry)
+
+	d.clientsMu.RLock()
+	defer d.clientsMu.RUnlock()
+
+	for c := range d.clients {
+		c.sub.deliver(entry, func() { c.send(msg) })
+	}
+}
+
 func (d *Daemon) handleMessage(c *clientConn, msg Message) {
 	switch msg.Type {
 	case MsgStart:
 		d.handleStart(c, msg)
 	case MsgStop:
-		d.handleStop(c)
+		d.handleStop(c, msg)
 	case MsgRestart:
 		d.handleRestart(c, msg)
 	case MsgStatus:
-		d.handleStatus(c)
+		d.handleStatus(c, msg)
 	case MsgLogs:
 		d.handleLogs(c, msg)
 	case MsgClearLogs:
 		d.handleClearLogs(c, msg)
 	case MsgCheckPorts:
-		d.handleCheckPorts(c)
+		d.handleCheckPorts(c, msg)
 	case MsgKillPorts:
 		d.handleKillPorts(c, msg)
+	case MsgStdin:
+		d.handleStdin(c, msg)
+	case MsgSubscribe:
+		d.handleSubscribe(c, msg)
+	case MsgShutdown:
+		d.handleShutdown(c, msg)
+	case MsgReload:
+		d.handleReload(c, msg)
 	}
 }
 
 func (d *Daemon) handleStart(c *clientConn, msg Message) {
 	req, err := ParsePayload[StartRequest](msg)
 	if err != nil {
-		d.sendError(c, err.Error())
+		d.sendError(c, msg, err.Error())
+		return
+	}
+
+	services, started, err := d.startServices(req.Services, req.KillPorts)
+	if err != nil {
+		d.sendError(c, msg, err.Error())
 		return
 	}
 
-	services := req.Services
+	resp, _ := NewMessage(MsgStarted, StartedResponse{Services: services, Started: started})
+	c.reply(msg, resp)
+}
+
+// startServices starts services (the defaults if none are given) that aren't
+// running yet, creating the runner on first use. Services already running
+// keep running. Returns the requested services with their dependencies and
+// the ones actually started.
+func (d *Daemon) startServices(services []string, killPorts bool) (resolved, started []string, err error) {
+	d.startMu.Lock()
+	defer d.startMu.Unlock()
+
+	cfg := d.GetConfig()
 	if len(services) == 0 {
-		services = d.config.Defaults
+		services = cfg.Defaults
 	}
 
 	// Validate services
 	for _, name := range services {
-		if _, ok := d.config.Services[name]; !ok {
-			d.sendError(c, fmt.Sprintf("unknown service: %s", name))
-			return
+		if _, ok := cfg.Services[name]; !ok {
+			return nil, nil, fmt.Errorf("unknown service: %s", name)
 		}
 	}
+	resolved = cfg.ResolveDependencies(services)
 
-	// Kill ports if requested
-	if req.KillPorts {
-		for _, name := range services {
-			if svc, ok := d.config.Services[name]; ok && svc.Port > 0 {
-				if runner.IsPortInUse(svc.Port) {
-					pid, _ := runner.GetPortPID(svc.Port)
-					if pid > 0 {
-						_ = runner.KillProcess(pid)
-					}
+	// Kill ports if requested, sparing services of our own
+	r := d.GetRunner()
+	if killPorts {
+		for _, name := range resolved {
+			if r != nil {
+				if state := r.State(name); state != nil && !state.Idle() {
+					continue
+				}
+			}
+			if svc := cfg.Services[name]; svc.Port > 0 && runner.IsPortInUse(svc.Port) {
+				pid, _ := runner.GetPortPID(svc.Port)
+				if pid > 0 {
+					_ = runner.KillProcess(pid)
 				}
 			}
 		}
 	}
 
-	// Create runner and start services
-	d.runner = runner.New(d.config, services, "", "")
-	d.runner.StartWithChannel()
+	if r == nil {
+		r = runner.New(cfg, resolved, "", "")
+		r.StartWithChannel()
+		d.runner.Store(r)
 
-	// Forward logs to all clients
-	go d.forwardLogs()
+		// Forward logs to all clients
+		go d.forwardLogs(r)
+		return resolved, resolved, nil
+	}
 
-	resp, _ := NewMessage(MsgStarted, StartedResponse{Services: services})
-	c.send(resp)
+	return resolved, r.AddServices(resolved), nil
 }
 
-func (d *Daemon) forwardLogs() {
-	if d.runner == nil {
-		return
-	}
-
+func (d *Daemon) forwardLogs(r *runner.Runner) {
 	for {
 		select {
 		case <-d.stopCh:
 			return
-		case entry := <-d.runner.LogEntryChan:
+		case entry := <-r.LogEntryChan:
 			logData := LogEntryData{
 				Time:    entry.Time,
 				Service: entry.Service,
 				Level:   entry.Level,
 				Message: entry.Message,
+				Fields:  entry.Fields,
+				Styled:  entry.Styled,
+				Seq:     entry.Seq,
+				Stream:  entry.Stream,
 			}
-			msg, _ := NewMessage(MsgLogEntry, logData)
-			d.broadcast(msg)
+			d.broadcastLog(logData)
 
 			// Also broadcast to WebSocket clients
 			if d.wsServer != nil {
 				d.wsServer.BroadcastLog(logData)
 			}
+
+			d.notifyStatus()
 		}
 	}
 }
 
-func (d *Daemon) handleStop(c *clientConn) {
-	if d.runner != nil {
-		d.runner.Stop()
+func (d *Daemon) handleStop(c *clientConn, msg Message) {
+	req, err := ParsePayload[StopRequest](msg)
+	if err != nil {
+		d.sendError(c, msg, err.Error())
+		return
+	}
+
+	for _, name := range req.Services {
+		if _, ok := d.GetConfig().Services[name]; !ok {
+			d.sendError(c, msg, fmt.Sprintf("unknown service: %s", name))
+			return
+		}
+	}
+
+	if r := d.GetRunner(); r != nil {
+		if len(req.Services) == 0 {
+			r.Stop()
+		} else {
+			// Dependents first, like a full stop
+			order := r.Order()
+			for i := len(order) - 1; i >= 0; i-- {
+				if slices.Contains(req.Services, order[i]) {
+					r.StopService(order[i])
+				}
+			}
+		}
 	}
 
 	resp, _ := NewMessage(MsgStopped, struct{}{})
-	c.send(resp)
+	c.reply(msg, resp)
 }
 
 func (d *Daemon) handleRestart(c *clientConn, msg Message) {
 	req, err := ParsePayload[RestartRequest](msg)
 	if err != nil {
-		d.sendError(c, err.Error())
+		d.sendError(c, msg, err.Error())
 		return
 	}
 
-	if d.runner == nil {
-		d.sendError(c, "no services running")
+	r := d.GetRunner()
+	if r == nil {
+		d.sendError(c, msg, "no services running")
 		return
 	}
 
-	if _, ok := d.runner.Services[req.Service]; !ok {
-		d.sendError(c, fmt.Sprintf("unknown service: %s", req.Service))
+	if _, ok := r.States()[req.Service]; !ok {
+		d.sendError(c, msg, fmt.Sprintf("unknown service: %s", req.Service))
 		return
 	}
 
-	d.runner.RestartService(req.Service)
+	r.RestartService(req.Service)
 
 	resp, _ := NewMessage(MsgRestarted, RestartedResponse(req))
-	c.send(resp)
+	c.reply(msg, resp)
 }
 
-func (d *Daemon) handleStatus(c *clientConn) {
+func (d *Daemon) handleStatus(c *clientConn, msg Message) {
 	var statuses []ServiceStatus
 
-	if d.runner != nil {
-		for name, state := range d.runner.Services {
-			state.Mu.Lock()
-
-			// Check for dynamic status from .devir-status file
-			icon := state.Service.Icon
-			color := state.Service.Color
-			status := string(state.Status)
-			message := ""
-
-			if ds := d.readDynamicStatus(state); ds != nil {
-				if ds.Icon != "" {
-					icon = ds.Icon
-				}
-				if ds.Color != "" {
-					color = ds.Color
-				}
-				if ds.Status != "" {
-					status = ds.Status
-				}
-				message = ds.Message
-			}
-
-			// Capture PID for metrics collection
-			var pid int
-			running := state.Running
-			if running && state.Cmd != nil && state.Cmd.Process != nil {
-				pid = state.Cmd.Process.Pid
-			}
-
-			s := ServiceStatus{
-				Name:     name,
-				Running:  running,
-				Port:     state.Service.Port,
-				Color:    color,
-				Icon:     icon,
-				Type:     string(state.Service.GetEffectiveType()),
-				Status:   status,
-				Message:  message,
-				ExitCode: state.ExitCode,
-				RunCount: state.RunCount,
-			}
-			if !state.LastRun.IsZero() {
-				s.LastRun = state.LastRun.Format(time.RFC3339)
-			}
-			if !state.NextRun.IsZero() {
-				s.NextRun = state.NextRun.Format(time.RFC3339)
-			}
-			state.Mu.Unlock()
+	if r := d.GetRunner(); r != nil {
+		for name, state := range r.States() {
+			s, pid := d.serviceStatus(name, state)
 
 			// Collect metrics after releasing lock
 			if pid > 0 {
@@ -428,12 +490,68 @@ func (d *Daemon) handleStatus(c *clientConn) {
 	}
 
 	resp, _ := NewMessage(MsgStatusResponse, StatusResponse{Services: statuses})
-	c.send(resp)
+	c.reply(msg, resp)
+}
+
+// serviceStatus returns the status of a service without metrics, and the
+// PID to collect them from (0 if not running)
+func (d *Daemon) serviceStatus(name string, state *runner.ServiceState) (ServiceStatus, int) {
+	state.Mu.Lock()
+	defer state.Mu.Unlock()
+
+	// Check for dynamic status from .devir-status file
+	icon := state.Service.Icon
+	color := state.Service.Color
+	status := string(state.Status)
+	message := ""
+
+	if ds := d.readDynamicStatus(state); ds != nil {
+		if ds.Icon != "" {
+			icon = ds.Icon
+		}
+		if ds.Color != "" {
+			color = ds.Color
+		}
+		if ds.Status != "" {
+			status = ds.Status
+		}
+		message = ds.Message
+	}
+
+	// Capture PID for metrics collection
+	var pid int
+	running := state.Running
+	if running && state.Cmd != nil && state.Cmd.Process != nil {
+		pid = state.Cmd.Process.Pid
+	}
+
+	s := ServiceStatus{
+		Name:       name,
+		Running:    running,
+		Port:       state.Service.Port,
+		Color:      color,
+		Icon:       icon,
+		Type:       string(state.Service.GetEffectiveType()),
+		Status:     status,
+		Message:    message,
+		ExitCode:   state.ExitCode,
+		RunCount:   state.RunCount,
+		Restarts:   state.Restarts,
+		CrashLoop:  state.CrashLoop,
+		StopResult: string(state.StopResult),
+	}
+	if !state.LastRun.IsZero() {
+		s.LastRun = state.LastRun.Format(time.RFC3339)
+	}
+	if !state.NextRun.IsZero() {
+		s.NextRun = state.NextRun.Format(time.RFC3339)
+	}
+	return s, pid
 }
 
 // readDynamicStatus reads status from .devir-status file in service directory
 func (d *Daemon) readDynamicStatus(state *runner.ServiceState) *types.DynamicStatus {
-	statusFile := filepath.Join(d.config.RootDir, state.Service.Dir, ".devir-status")
+	statusFile := filepath.Join(d.GetConfig().RootDir, state.Service.Dir, ".devir-status")
 	data, err := os.ReadFile(statusFile)
 	if err != nil {
 		return nil
@@ -459,72 +577,26 @@ func (d *Daemon) readDynamicStatus(state *runner.ServiceState) *types.DynamicSta
 	return &types.DynamicStatus{Icon: content}
 }
 
-func (d *Daemon) handleLogs(c *clientConn, msg Message) {
-	req, err := ParsePayload[LogsRequest](msg)
-	if err != nil {
-		d.sendError(c, err.Error())
-		return
-	}
-
-	lines := req.Lines
-	if lines <= 0 {
-		lines = 100
-	}
-
-	var logs []LogEntryData
-
-	if d.runner != nil {
-		for name, state := range d.runner.Services {
-			if req.Service != "" && name != req.Service {
-				continue
-			}
-
-			state.Mu.Lock()
-			startIdx := 0
-			if len(state.Logs) > lines {
-				startIdx = len(state.Logs) - lines
-			}
-
-			for _, log := range state.Logs[startIdx:] {
-				level := "info"
-				if log.IsError {
-					level = "error"
-				}
-				logs = append(logs, LogEntryData{
-					Time:    log.Timestamp,
-					Service: name,
-					Level:   level,
-					Message: log.Text,
-				})
-			}
-			state.Mu.Unlock()
-		}
-	}
-
-	resp, _ := NewMessage(MsgLogsResponse, LogsResponse{Logs: logs})
-	c.send(resp)
-}
-
 func (d *Daemon) handleClearLogs(c *clientConn, msg Message) {
 	req, err := ParsePayload[ClearLogsRequest](msg)
 	if err != nil {
-		d.sendError(c, err.Error())
+		d.sendError(c, msg, err.Error())
 		return
 	}
 
-	if d.runner != nil {
-		d.runner.ClearLogs(req.Service)
+	if r := d.GetRunner(); r != nil {
+		r.ClearLogs(req.Service)
 	}
 
 	resp, _ := NewMessage(MsgLogsCleared, struct{}{})
-	c.send(resp)
+	c.reply(msg, resp)
 }
 
-func (d *Daemon) handleCheckPorts(c *clientConn) {
+func (d *Daemon) handleCheckPorts(c *clientConn, msg Message) {
 	var ports []PortInfo
 	hasConflict := false
 
-	for name, svc := range d.config.Services {
+	for name, svc := range d.GetConfig().Services {
 		if svc.Port > 0 {
 			inUse := runner.IsPortInUse(svc.Port)
 			if inUse {
@@ -539,13 +611,13 @@ func (d *Daemon) handleCheckPorts(c *clientConn) {
 	}
 
 	resp, _ := NewMessage(MsgPortsResponse, PortsResponse{Ports: ports, HasConflict: hasConflict})
-	c.send(resp)
+	c.reply(msg, resp)
 }
 
 func (d *Daemon) handleKillPorts(c *clientConn, msg Message) {
 	req, err := ParsePayload[KillPortsRequest](msg)
 	if err != nil {
-		d.sendError(c, err.Error())
+		d.sendError(c, msg, err.Error())
 		return
 	}
 
@@ -567,60 +639,100 @@ func (d *Daemon) handleKillPorts(c *clientConn, msg Message) {
 	}
 
 	resp, _ := NewMessage(MsgKillResponse, KillPortsResponse{Killed: killed, Failed: failed})
-	c.send(resp)
+	c.reply(msg, resp)
 }
 
-func (d *Daemon) sendError(c *clientConn, errMsg string) {
-	resp, _ := NewMessage(MsgError, ErrorResponse{Error: errMsg})
-	c.send(resp)
-}
+func (d *Daemon) handleSubscribe(c *clientConn, msg Message) {
+	req, err := ParsePayload[SubscribeRequest](msg)
+	if err != nil {
+		d.sendError(c, msg, err.Error())
+		return
+	}
 
-// GetRunner returns the runner (for embedded mode)
-func (d *Daemon) GetRunner() *runner.Runner {
-	return d.runner
+	filter, err := newLogFilter(req.Services, req.Level, req.Match)
+	if err != nil {
+		d.sendError(c, msg, err.Error())
+		return
+	}
+
+	if req.After == nil {
+		c.sub.set(filter)
+		resp, _ := NewMessage(MsgSubscribed, SubscribedResponse{SubscribeRequest: req})
+		c.reply(msg, resp)
+		return
+	}
+
+	// The replay goes out with the reply, ahead of newer entries
+	c.sub.resume(filter, d.GetRunner(), *req.After, maxReplayLines, func(logs []LogEntryData) {
+		resp, _ := NewMessage(MsgSubscribed, SubscribedResponse{SubscribeRequest: req, Logs: logs})
+		c.reply(msg, resp)
+	})
 }
 
-// GetConfig returns the config
-func (d *Daemon) GetConfig() *config.Config {
-	return d.config
+// handleShutdown stops the services before replying, so the client knows
+// they are down once it gets the response
+func (d *Daemon) handleShutdown(c *clientConn, msg Message) {
+	if d.shutdownCh == nil {
+		d.sendError(c, msg, "daemon belongs to a devir TUI or MCP session, quit that instead")
+		return
+	}
+
+	if r := d.GetRunner(); r != nil {
+		r.Stop()
+	}
+
+	resp, _ := NewMessage(MsgShuttingDown, struct{}{})
+	c.reply(msg, resp)
+	d.shutdownOnce.Do(func() { close(d.shutdownCh) })
 }
 
-// StartServices starts services directly (for embedded mode without client)
-func (d *Daemon) StartServices(services []string, killPorts bool) error {
-	if len(services) == 0 {
-		services = d.config.Defaults
+func (d *Daemon) handleStdin(c *clientConn, msg Message) {
+	req, err := ParsePayload[StdinRequest](msg)
+	if err != nil {
+		d.sendError(c, msg, err.Error())
+		return
 	}
 
-	for _, name := range services {
-		if _, ok := d.config.Services[name]; !ok {
-			return fmt.Errorf("unknown service: %s", name)
-		}
+	r := d.GetRunner()
+	if r == nil {
+		d.sendError(c, msg, "no services running")
+		return
 	}
 
-	if killPorts {
-		for _, name := range services {
-			if svc, ok := d.config.Services[name]; ok && svc.Port > 0 {
-				if runner.IsPortInUse(svc.Port) {
-					pid, _ := runner.GetPortPID(svc.Port)
-					if pid > 0 {
-						_ = runner.KillProcess(pid)
-					}
-				}
-			}
-		}
+	// Sent for every keystroke, so success is not acknowledged
+	if err := r.WriteStdin(req.Service, []byte(req.Data)); err != nil {
+		d.sendError(c, msg, err.Error())
 	}
+}
 
-	d.runner = runner.New(d.config, services, "", "")
-	d.runner.StartWithChannel()
-	go d.forwardLogs()
+// sendError reports a failed request to the client that sent it
+func (d *Daemon) sendError(c *clientConn, req Message, errMsg string) {
+	resp, _ := NewMessage(MsgError, ErrorResponse{Error: errMsg})
+	c.reply(req, resp)
+}
 
-	return nil
+// GetRunner returns the runner, nil until services are first started
+func (d *Daemon) GetRunner() *runner.Runner {
+	return d.runner.Load()
+}
+
+// GetConfig returns the config, which a reload may replace
+func (d *Daemon) GetConfig() *config.Config {
+	d.configMu.RLock()
+	defer d.configMu.RUnlock()
+	return d.config
+}
+
+// StartServices starts services directly (for embedded mode without client)
+func (d *Daemon) StartServices(services []string, killPorts bool) error {
+	_, _, err := d.startServices(services, killPorts)
+	return err
 }
 
 // LogEntryChan returns the log entry channel for embedded mode
 func (d *Daemon) LogEntryChan() <-chan types.LogEntry {
-	if d.runner != nil {
-		return d.runner.LogEntryChan
+	if r := d.GetRunner(); r != nil {
+		return r.LogEntryChan
 	}
 	return nil
 }
@@ -0,0 +1,130 @@
+package daemon
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"devir/internal/runner"
+	"devir/internal/types"
+)
+
+// defaultLogLines is how many entries a logs request returns without a limit
+const defaultLogLines = 100
+
+func (d *Daemon) handleLogs(c *clientConn, msg Message) {
+	req, err := ParsePayload[LogsRequest](msg)
+	if err != nil {
+		d.sendError(c, msg, err.Error())
+		return
+	}
+
+	logs, err := d.queryLogs(req)
+	if err != nil {
+		d.sendError(c, msg, err.Error())
+		return
+	}
+
+	resp, _ := NewMessage(MsgLogsResponse, logs)
+	c.reply(msg, resp)
+}
+
+// queryLogs answers a logs request from the runner's buffers and log files
+func (d *Daemon) queryLogs(req LogsRequest) (LogsResponse, error) {
+	services := req.Services
+	if req.Service != "" {
+		services = append(slices.Clone(services), req.Service)
+	}
+	cfg := d.GetConfig()
+	for _, name := range services {
+		if _, ok := cfg.Services[name]; !ok {
+			return LogsResponse{}, fmt.Errorf("unknown service: %s", name)
+		}
+	}
+
+	// Services are selected by the query itself
+	filter, err := newLogFilter(nil, req.Level, req.Match)
+	if err != nil {
+		return LogsResponse{}, err
+	}
+	cursor, err := parseLogCursor(req.Cursor)
+	if err != nil {
+		return LogsResponse{}, err
+	}
+
+	lines := req.Lines
+	if lines <= 0 {
+		lines = defaultLogLines
+	}
+
+	r := d.GetRunner()
+	if r == nil {
+		return LogsResponse{}, nil
+	}
+
+	found, next := r.QueryLogs(runner.LogQuery{
+		Services: services,
+		Since:    req.Since,
+		Until:    req.Until,
+		Match:    func(line types.LogLine) bool { return filter.matches(logEntryFromLine(line)) },
+		Limit:    lines,
+		Cursor:   cursor,
+	})
+
+	resp := LogsResponse{Logs: make([]LogEntryData, 0, len(found))}
+	for _, line := range found {
+		resp.Logs = append(resp.Logs, logEntryFromLine(line))
+	}
+	if next != nil {
+		resp.Cursor = formatLogCursor(next)
+	}
+	return resp, nil
+}
+
+// logEntryFromLine converts a stored log line
+func logEntryFromLine(line types.LogLine) LogEntryData {
+	level := line.Level
+	if level == "" {
+		level = "info"
+		if line.IsError {
+			level = "error"
+		}
+	}
+	return LogEntryData{
+		Time:    line.Timestamp,
+		Service: line.Service,
+		Level:   level,
+		Message: line.Text,
+		Fields:  line.Fields,
+		Styled:  line.Styled,
+		Seq:     line.Seq,
+		Stream:  line.Stream,
+	}
+}
+
+// formatLogCursor encodes a cursor as "<unix nanos>:<skip>:<service>".
+// Clients treat it as opaque.
+func formatLogCursor(c *runner.LogCursor) string {
+	return fmt.Sprintf("%d:%d:%s", c.Time.UnixNano(), c.Skip, c.Service)
+}
+
+func parseLogCursor(s string) (*runner.LogCursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.SplitN(s, ":", 3)
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid cursor %q", s)
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor %q", s)
+	}
+	skip, err := strconv.Atoi(parts[1])
+	if err != nil || skip < 0 {
+		return nil, fmt.Errorf("invalid cursor %q", s)
+	}
+	return &runner.LogCursor{Time: time.Unix(0, nanos), Service: parts[2], Skip: skip}, nil
+}
@@ -5,6 +5,16 @@ import (
 	"time"
 )
 
+// ProtocolVersion is incremented on incompatible protocol changes. Clients
+// refuse to talk to a daemon with a different version.
+const ProtocolVersion = 1
+
+// Version is the devir version reported in the handshake, set by main
+var Version = "dev"
+
+// Capabilities lists the optional protocol features this build supports
+var Capabilities = []string{"request_ids", "stdin", "status_events", "subscribe", "shutdown", "reload", "log_query"}
+
 // Message types
 const (
 	// Client → Daemon
@@ -16,8 +26,13 @@ const (
 	MsgClearLogs  = "clear_logs"
 	MsgCheckPorts = "check_ports"
 	MsgKillPorts  = "kill_ports"
+	MsgStdin      = "stdin" // Not acknowledged; only failures get an error
+	MsgSubscribe  = "subscribe"
+	MsgShutdown   = "shutdown" // Stops the services and a detached daemon
+	MsgReload     = "reload"   // Reloads devir.yaml
 
 	// Daemon → Client
+	MsgHello          = "hello" // First message on every connection
 	MsgStarted        = "started"
 	MsgStopped        = "stopped"
 	MsgRestarted      = "restarted"
@@ -26,13 +41,21 @@ const (
 	MsgLogsCleared    = "logs_cleared"
 	MsgPortsResponse  = "ports_response"
 	MsgKillResponse   = "kill_response"
-	MsgLogEntry       = "log_entry" // Broadcast to all clients
+	MsgSubscribed     = "subscribed"
+	MsgShuttingDown   = "shutting_down"  // Services stopped, the daemon exits next
+	MsgReloaded       = "reloaded"       // Response to reload, with the ConfigChange
+	MsgConfigChanged  = "config_changed" // Broadcast with the ConfigChange after every reload
+	MsgLogEntry       = "log_entry"      // Broadcast to all clients
+	MsgStatusChanged  = "status_changed" // Broadcast with the new ServiceStatus (without metrics)
 	MsgError          = "error"
 )
 
-// Message is the wire format for daemon communication
+// Message is the wire format for daemon communication. A request may carry
+// an ID, which the daemon copies to its response or error so the client can
+// match them up; broadcasts have no ID.
 type Message struct {
 	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
 	Payload json.RawMessage `json:"payload,omitempty"`
 }
 
@@ -55,23 +78,55 @@ func ParsePayload[T any](msg Message) (T, error) {
 	return result, err
 }
 
+// Hello describes the daemon to a connecting client
+type Hello struct {
+	ProtocolVersion int      `json:"protocolVersion"`
+	Version         string   `json:"version"`              // devir version
+	Capabilities    []string `json:"capabilities"`         // optional features supported
+	ConfigHash      string   `json:"configHash,omitempty"` // hash of the daemon's devir.yaml
+}
+
+// newHello describes this build
+func newHello(configHash string) Hello {
+	return Hello{
+		ProtocolVersion: ProtocolVersion,
+		Version:         Version,
+		Capabilities:    Capabilities,
+		ConfigHash:      configHash,
+	}
+}
+
 // --- Request payloads (Client → Daemon) ---
 
-// StartRequest requests starting services
+// StartRequest requests starting services. Services that are already
+// running are left alone, so a request can add services to the others.
 type StartRequest struct {
 	Services  []string `json:"services,omitempty"`
 	KillPorts bool     `json:"killPorts,omitempty"`
 }
 
+// StopRequest requests stopping services. Other services keep running;
+// an empty request stops all of them.
+type StopRequest struct {
+	Services []string `json:"services,omitempty"`
+}
+
 // RestartRequest requests restarting a service
 type RestartRequest struct {
 	Service string `json:"service"`
 }
 
-// LogsRequest requests logs from services
+// LogsRequest queries stored logs. Entries of the selected services are
+// merged by time, and the newest Lines matching ones are returned.
 type LogsRequest struct {
-	Service string `json:"service,omitempty"`
-	Lines   int    `json:"lines,omitempty"`
+	Service  string    `json:"service,omitempty"`  // a single service, like Services
+	Services []string  `json:"services,omitempty"` // empty = all services
+	Lines    int       `json:"lines,omitempty"`    // default 100
+	Since    time.Time `json:"since,omitzero"`     // only entries at or after this time
+	Until    time.Time `json:"until,omitzero"`     // only entries before this time
+	Level    string    `json:"level,omitempty"`    // minimum level: debug, info, warn, error
+	Match    string    `json:"match,omitempty"`    // regex the message must match
+	Cursor   string    `json:"cursor,omitempty"`   // from a previous response, continues with older entries
 }
 
 // KillPortsRequest requests killing processes on ports
@@ -84,11 +139,34 @@ type ClearLogsRequest struct {
 	Service string `json:"service,omitempty"`
 }
 
+// StdinRequest writes input to a running service
+type StdinRequest struct {
+	Service string `json:"service"`
+	Data    string `json:"data"`
+}
+
+// SubscribeRequest limits the log entries broadcast to a client. Each
+// request replaces the previous one; an empty request receives everything.
+type SubscribeRequest struct {
+	Services []string `json:"services,omitempty"` // empty = all services
+	Level    string   `json:"level,omitempty"`    // minimum level: debug, info, warn, error
+	Match    string   `json:"match,omitempty"`    // regex the message must match
+	After    *uint64  `json:"after,omitempty"`    // replay the stored entries with a higher seq first
+}
+
 // --- Response payloads (Daemon → Client) ---
 
 // StartedResponse confirms services started
 type StartedResponse struct {
-	Services []string `json:"services"`
+	Services []string `json:"services"`          // requested services and their dependencies
+	Started  []string `json:"started,omitempty"` // those that weren't running before
+}
+
+// SubscribedResponse confirms a subscription. Entries replayed for After
+// come with it, so they arrive before any later entry.
+type SubscribedResponse struct {
+	SubscribeRequest
+	Logs []LogEntryData `json:"logs,omitempty"` // oldest first
 }
 
 // RestartedResponse confirms service restarted
@@ -98,20 +176,23 @@ type RestartedResponse struct {
 
 // ServiceStatus represents a service's current state
 type ServiceStatus struct {
-	Name     string  `json:"name"`
-	Running  bool    `json:"running"`
-	Port     int     `json:"port"`
-	Color    string  `json:"color"`
-	Icon     string  `json:"icon"`     // custom icon/emoji
-	Type     string  `json:"type"`     // service, oneshot, interval, http
-	Status   string  `json:"status"`   // running, completed, failed, waiting, stopped
-	Message  string  `json:"message"`  // dynamic status message
-	LastRun  string  `json:"lastRun"`  // ISO timestamp
-	NextRun  string  `json:"nextRun"`  // ISO timestamp (for interval)
-	ExitCode int     `json:"exitCode"` // last exit code
-	RunCount int     `json:"runCount"` // number of runs
-	CPU      float64 `json:"cpu"`      // CPU percentage
-	Memory   uint64  `json:"memory"`   // Memory in bytes (RSS)
+	Name       string  `json:"name"`
+	Running    bool    `json:"running"`
+	Port       int     `json:"port"`
+	Color      string  `json:"color"`
+	Icon       string  `json:"icon"`                 // custom icon/emoji
+	Type       string  `json:"type"`                 // service, oneshot, interval, http
+	Status     string  `json:"status"`               // running, starting, healthy, unhealthy, restarting, crashloop, completed, failed, waiting, stopped
+	Message    string  `json:"message"`              // dynamic status message
+	LastRun    string  `json:"lastRun"`              // ISO timestamp
+	NextRun    string  `json:"nextRun"`              // ISO timestamp (for interval)
+	ExitCode   int     `json:"exitCode"`             // last exit code
+	RunCount   int     `json:"runCount"`             // number of runs
+	Restarts   int     `json:"restarts"`             // consecutive automatic restarts
+	CrashLoop  bool    `json:"crashLoop"`            // restarting repeatedly without staying up
+	StopResult string  `json:"stopResult,omitempty"` // how the last stop ended: graceful, killed
+	CPU        float64 `json:"cpu"`                  // CPU percentage
+	Memory     uint64  `json:"memory"`               // Memory in bytes (RSS)
 }
 
 // StatusResponse contains all service statuses
@@ -121,15 +202,20 @@ type StatusResponse struct {
 
 // LogEntryData is a single log entry for broadcast
 type LogEntryData struct {
-	Time    time.Time `json:"time"`
-	Service string    `json:"service"`
-	Level   string    `json:"level"`
-	Message string    `json:"message"`
+	Time    time.Time         `json:"time"`
+	Service string            `json:"service"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"` // extra fields of structured lines
+	Styled  string            `json:"styled,omitempty"` // message with ANSI colors (tty services)
+	Seq     uint64            `json:"seq,omitempty"`    // increases with every stored entry, 0 for entries read from log files
+	Stream  string            `json:"stream,omitempty"` // stdout, stderr or devir
 }
 
-// LogsResponse contains requested logs
+// LogsResponse contains requested logs, oldest first
 type LogsResponse struct {
-	Logs []LogEntryData `json:"logs"`
+	Logs   []LogEntryData `json:"logs"`
+	Cursor string         `json:"cursor,omitempty"` // set if older entries remain
 }
 
 // PortInfo represents port status
@@ -145,6 +231,18 @@ type PortsResponse struct {
 	HasConflict bool       `json:"hasConflict"`
 }
 
+// ConfigChange reports a config reload: the services that were added,
+// removed or changed, and the ones started as a result. A failed automatic
+// reload is broadcast with Error set and the old config kept.
+type ConfigChange struct {
+	Added      []string `json:"added,omitempty"`
+	Removed    []string `json:"removed,omitempty"`
+	Changed    []string `json:"changed,omitempty"`
+	Started    []string `json:"started,omitempty"`
+	ConfigHash string   `json:"configHash,omitempty"`
+	Error      string   `json:"error,omitempty"`
+}
+
 // KillPortsResponse contains kill results
 type KillPortsResponse struct {
 	Killed []int `json:"killed"`
@@ -0,0 +1,128 @@
+package daemon
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"devir/internal/config"
+)
+
+// configDebounce lets an editor finish saving before devir.yaml is reloaded
+const configDebounce = 300 * time.Millisecond
+
+// reload loads devir.yaml again and applies it to the running services
+func (d *Daemon) reload() (ConfigChange, error) {
+	d.startMu.Lock()
+	defer d.startMu.Unlock()
+
+	old := d.GetConfig()
+	if old.Path == "" {
+		return ConfigChange{}, fmt.Errorf("config file unknown")
+	}
+	cfg, err := config.Load(old.Path)
+	if err != nil {
+		return ConfigChange{}, err
+	}
+
+	diff := config.DiffServices(old, cfg)
+	d.configMu.Lock()
+	d.config = cfg
+	d.configMu.Unlock()
+
+	change := ConfigChange{
+		Added:      diff.Added,
+		Removed:    diff.Removed,
+		Changed:    diff.Changed,
+		ConfigHash: cfg.Hash,
+	}
+	if r := d.GetRunner(); r != nil {
+		change.Started = r.Reload(cfg, diff)
+	}
+	d.notifyStatus()
+	return change, nil
+}
+
+func (d *Daemon) handleReload(c *clientConn, msg Message) {
+	change, err := d.reload()
+	if err != nil {
+		d.sendError(c, msg, err.Error())
+		return
+	}
+
+	resp, _ := NewMessage(MsgReloaded, change)
+	c.reply(msg, resp)
+	d.broadcastConfigChange(change)
+}
+
+// watchConfig reloads devir.yaml when it is saved
+func (d *Daemon) watchConfig() {
+	defer d.wg.Done()
+
+	path := d.GetConfig().Path
+	if path == "" {
+		return
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return
+	}
+	defer func() { _ = watcher.Close() }()
+
+	// Editors often save by replacing the file, which would end a watch on
+	// the file itself
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		return
+	}
+
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Base(event.Name) != filepath.Base(path) || event.Op == fsnotify.Chmod {
+				continue
+			}
+			debounce = time.After(configDebounce)
+		case <-debounce:
+			debounce = nil
+			d.autoReload()
+		case <-watcher.Errors:
+			// Errors (e.g. queue overflow) are not fatal; keep watching
+		}
+	}
+}
+
+// autoReload reloads after a file change. Failures are broadcast, since no
+// client asked for the reload.
+func (d *Daemon) autoReload() {
+	before := d.GetConfig().Hash
+
+	change, err := d.reload()
+	if err != nil {
+		d.broadcastConfigChange(ConfigChange{ConfigHash: before, Error: err.Error()})
+		return
+	}
+	// Saved without changes
+	if change.ConfigHash == before {
+		return
+	}
+	d.broadcastConfigChange(change)
+}
+
+// broadcastConfigChange reports a reload to socket and WebSocket clients
+func (d *Daemon) broadcastConfigChange(change ConfigChange) {
+	msg, _ := NewMessage(MsgConfigChanged, change)
+	d.broadcast(msg)
+
+	if d.wsServer != nil {
+		d.wsServer.BroadcastConfigChange(change)
+	}
+}
@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"time"
+)
+
+const (
+	// statusPollInterval is how often service states are compared when no
+	// logs arrive; it also picks up .devir-status files
+	statusPollInterval = 250 * time.Millisecond
+
+	// statusCheckGap limits checks triggered by log lines under heavy output
+	statusCheckGap = 50 * time.Millisecond
+)
+
+// notifyStatus asks the status watcher to look for changes soon. Most state
+// changes come with a log line, so forwardLogs calls this for every entry.
+func (d *Daemon) notifyStatus() {
+	select {
+	case d.statusCheck <- struct{}{}:
+	default:
+	}
+}
+
+// watchStatus broadcasts status_changed to all clients whenever a service's
+// status, exit code, run count or dynamic status changes
+func (d *Daemon) watchStatus() {
+	defer d.wg.Done()
+
+	ticker := time.NewTicker(statusPollInterval)
+	defer ticker.Stop()
+
+	last := make(map[string]ServiceStatus)
+	var lastCheck time.Time
+
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case <-ticker.C:
+		case <-d.statusCheck:
+			if time.Since(lastCheck) < statusCheckGap {
+				continue
+			}
+		}
+		lastCheck = time.Now()
+
+		r := d.GetRunner()
+		if r == nil {
+			continue
+		}
+		for _, name := range r.Order() {
+			state := r.State(name)
+			if state == nil {
+				continue
+			}
+			s, _ := d.serviceStatus(name, state)
+			if prev, ok := last[name]; ok && prev == s {
+				continue
+			}
+			last[name] = s
+			d.broadcastStatus(s)
+		}
+	}
+}
+
+// broadcastStatus sends a changed service status to socket and WebSocket
+// clients
+func (d *Daemon) broadcastStatus(s ServiceStatus) {
+	msg, _ := NewMessage(MsgStatusChanged, s)
+	d.broadcast(msg)
+
+	if d.wsServer != nil {
+		d.wsServer.BroadcastStatus(s)
+	}
+}
@@ -0,0 +1,139 @@
+package daemon
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"devir/internal/config"
+	"devir/internal/runner"
+	"devir/internal/types"
+)
+
+// logFilter selects log entries by service, minimum level and a regex on
+// the message. The zero value matches everything.
+type logFilter struct {
+	services []string // empty = all
+	minLevel int      // index into config.LogLevels
+	re       *regexp.Regexp
+}
+
+// newLogFilter validates and compiles a filter. Empty arguments don't
+// restrict anything.
+func newLogFilter(services []string, level, match string) (*logFilter, error) {
+	f := &logFilter{services: services}
+
+	if level != "" {
+		f.minLevel = slices.Index(config.LogLevels, level)
+		if f.minLevel < 0 {
+			return nil, fmt.Errorf("unknown level %q (use one of %s)", level, strings.Join(config.LogLevels, ", "))
+		}
+	}
+
+	if match != "" {
+		re, err := regexp.Compile(match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match: %w", err)
+		}
+		f.re = re
+	}
+	return f, nil
+}
+
+// matches reports whether the filter selects a log entry
+func (f *logFilter) matches(entry LogEntryData) bool {
+	if f == nil {
+		return true
+	}
+	if len(f.services) > 0 && !slices.Contains(f.services, entry.Service) {
+		return false
+	}
+	// Entries with unknown levels always pass the level check
+	if lvl := slices.Index(config.LogLevels, entry.Level); lvl >= 0 && lvl < f.minLevel {
+		return false
+	}
+	return f.re == nil || f.re.MatchString(entry.Message)
+}
+
+// maxReplayLines caps how many entries a subscription replays
+const maxReplayLines = 1000
+
+// subscription holds a client's current log filter. Clients that never
+// subscribe receive every entry.
+type subscription struct {
+	mu       sync.Mutex
+	filter   *logFilter
+	first    uint64 // seq of the first live entry sent, 0 if none yet
+	sent     uint64 // seq of the last live entry sent
+	replayed uint64 // live entries up to this seq were replayed already
+}
+
+func (s *subscription) set(f *logFilter) {
+	s.mu.Lock()
+	s.filter = f
+	s.first, s.sent = 0, 0
+	s.mu.Unlock()
+}
+
+func (s *subscription) current() *logFilter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.filter
+}
+
+// hold drops live entries until resume replays them. New clients call it
+// before they are registered for broadcasts.
+func (s *subscription) hold() {
+	s.mu.Lock()
+	s.replayed = math.MaxUint64
+	s.mu.Unlock()
+}
+
+// resume sets the filter and passes the stored entries numbered after seq
+// to send, except those the client got live already. Live entries that were
+// among them are skipped later. If more than limit entries are left, only
+// the newest are sent.
+func (s *subscription) resume(f *logFilter, r *runner.Runner, seq uint64, limit int, send func([]LogEntryData)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.filter
+	s.filter = f
+	s.replayed = 0
+
+	var logs []LogEntryData
+	if r != nil {
+		lines, last := r.LinesAfter(seq, func(line types.LogLine) bool {
+			entry := logEntryFromLine(line)
+			// Live entries go out in seq order, so the client has every entry
+			// between the first and the last one sent that matched
+			if s.first != 0 && line.Seq >= s.first && line.Seq <= s.sent && prev.matches(entry) {
+				return false
+			}
+			return f.matches(entry)
+		}, min(limit, maxReplayLines))
+		for _, line := range lines {
+			logs = append(logs, logEntryFromLine(line))
+		}
+		s.replayed = last
+	}
+	s.first, s.sent = 0, 0
+	send(logs)
+}
+
+// deliver calls send if the client wants a live entry. Holding the lock
+// keeps live entries from overtaking a replay.
+func (s *subscription) deliver(entry LogEntryData, send func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry.Seq <= s.replayed || !s.filter.matches(entry) {
+		return
+	}
+	if s.first == 0 {
+		s.first = entry.Seq
+	}
+	s.sent = entry.Seq
+	send()
+}
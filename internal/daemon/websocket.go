@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+
+	"devir/internal/types"
 )
 
 const (
@@ -23,8 +27,12 @@ const (
 	// Send pings to peer with this period (must be less than pongWait)
 	pingPeriod = (pongWait * 9) / 10
 
-	// Maximum message size allowed from peer
-	maxMessageSize = 512
+	// Maximum message size allowed from peer (subscribe commands carry filters)
+	maxMessageSize = 4096
+
+	// Entries replayed to a new client or for the history action, unless
+	// another number is asked for
+	defaultReplayLines = 200
 )
 
 // WSServer handles WebSocket connections for browser clients
@@ -41,15 +49,42 @@ type wsClient struct {
 	conn   *websocket.Conn
 	sendCh chan []byte
 	server *WSServer
+	sub    subscription
 }
 
 // WSLogMessage is the JSON message sent to WebSocket clients
 type WSLogMessage struct {
-	Type    string    `json:"type"`
-	Time    time.Time `json:"time"`
-	Service string    `json:"service"`
-	Level   string    `json:"level"`
-	Message string    `json:"message"`
+	Type    string            `json:"type"`
+	Time    time.Time         `json:"time"`
+	Service string            `json:"service"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+	Seq     uint64            `json:"seq,omitempty"`
+	Stream  string            `json:"stream,omitempty"` // stdout, stderr or devir
+}
+
+// WSLogsMessage carries stored entries, oldest first. The logs action is
+// answered with a "logs" message; entries replayed on connect, for the
+// history action or a subscribe after a seq come in a "history" message.
+type WSLogsMessage struct {
+	Type   string         `json:"type"`
+	Logs   []WSLogMessage `json:"logs"`
+	Cursor string         `json:"cursor,omitempty"` // set if older entries remain
+}
+
+// newWSLogMessage converts a log entry for WebSocket clients
+func newWSLogMessage(entry LogEntryData) WSLogMessage {
+	return WSLogMessage{
+		Type:    "log",
+		Time:    entry.Time,
+		Service: entry.Service,
+		Level:   entry.Level,
+		Message: entry.Message,
+		Fields:  entry.Fields,
+		Seq:     entry.Seq,
+		Stream:  entry.Stream,
+	}
 }
 
 // WSStatusMessage is the JSON message for service status
@@ -58,21 +93,73 @@ type WSStatusMessage struct {
 	Services []WSServiceStatus `json:"services"`
 }
 
+// WSStatusChangedMessage is sent when a service's status changes
+type WSStatusChangedMessage struct {
+	Type    string          `json:"type"`
+	Service WSServiceStatus `json:"service"`
+}
+
+// WSConfigChangedMessage is sent after devir.yaml is reloaded
+type WSConfigChangedMessage struct {
+	Type string `json:"type"`
+	ConfigChange
+}
+
 // WSServiceStatus represents a service status for WebSocket
 type WSServiceStatus struct {
-	Name    string `json:"name"`
-	Running bool   `json:"running"`
-	Status  string `json:"status"` // running, stopped, completed, failed, waiting
-	Port    int    `json:"port,omitempty"`
-	Color   string `json:"color"`
-	Icon    string `json:"icon,omitempty"`
-	Type    string `json:"type,omitempty"` // service, oneshot, interval, http
+	Name       string `json:"name"`
+	Running    bool   `json:"running"`
+	Status     string `json:"status"`            // running, starting, healthy, unhealthy, restarting, crashloop, stopped, completed, failed, waiting
+	Message    string `json:"message,omitempty"` // dynamic status message
+	Port       int    `json:"port,omitempty"`
+	Color      string `json:"color"`
+	Icon       string `json:"icon,omitempty"`
+	Type       string `json:"type,omitempty"` // service, oneshot, interval, http
+	ExitCode   int    `json:"exitCode,omitempty"`
+	RunCount   int    `json:"runCount,omitempty"`
+	Restarts   int    `json:"restarts,omitempty"`
+	CrashLoop  bool   `json:"crashLoop,omitempty"`
+	StopResult string `json:"stopResult,omitempty"` // graceful, killed
+}
+
+// newWSServiceStatus converts a daemon service status for WebSocket clients
+func newWSServiceStatus(s ServiceStatus) WSServiceStatus {
+	return WSServiceStatus{
+		Name:       s.Name,
+		Running:    s.Running,
+		Status:     s.Status,
+		Message:    s.Message,
+		Port:       s.Port,
+		Color:      s.Color,
+		Icon:       s.Icon,
+		Type:       s.Type,
+		ExitCode:   s.ExitCode,
+		RunCount:   s.RunCount,
+		Restarts:   s.Restarts,
+		CrashLoop:  s.CrashLoop,
+		StopResult: s.StopResult,
+	}
 }
 
 // WSCommand is an incoming command from WebSocket client
 type WSCommand struct {
-	Action  string `json:"action"`  // restart, stop, start, clear
+	Action  string `json:"action"`  // restart, stop, start, clear, status, subscribe, reload, logs, history
 	Service string `json:"service"` // service name (optional for some actions)
+
+	// Filters of the subscribe and logs actions, see SubscribeRequest
+	Services []string `json:"services,omitempty"`
+	Level    string   `json:"level,omitempty"`
+	Match    string   `json:"match,omitempty"`
+
+	// Replay of the subscribe and history actions, see SubscribeRequest
+	After *uint64 `json:"after,omitempty"`
+
+	// Further options of the logs action, see LogsRequest. Lines also
+	// limits the history action.
+	Lines  int       `json:"lines,omitempty"`
+	Since  time.Time `json:"since,omitzero"`
+	Until  time.Time `json:"until,omitzero"`
+	Cursor string    `json:"cursor,omitempty"`
 }
 
 // WSResponse is a response to a command
@@ -162,10 +249,31 @@ func (ws *WSServer) handleLogs(w http.ResponseWriter, r *http.Request) {
 		server: ws,
 	}
 
+	// New clients get the recent entries first, so they start with some
+	// context. A reconnecting client passes the seq of the last entry it got
+	// instead, and gets the ones it missed.
+	query := r.URL.Query()
+	after, _ := strconv.ParseUint(query.Get("after"), 10, 64)
+	lines := defaultReplayLines
+	if n, err := strconv.Atoi(query.Get("lines")); err == nil {
+		lines = n
+	} else if query.Has("after") {
+		lines = maxReplayLines
+	}
+	if lines > 0 {
+		client.sub.hold()
+	}
+
 	ws.mu.Lock()
 	ws.clients[client] = true
 	ws.mu.Unlock()
 
+	if lines > 0 {
+		client.sub.resume(nil, ws.daemon.GetRunner(), after, lines, func(logs []LogEntryData) {
+			client.trySend(newWSLogsMessage("history", logs, ""))
+		})
+	}
+
 	go client.writePump()
 	go client.readPump()
 }
@@ -177,57 +285,70 @@ func (ws *WSServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Send current status and close
-	var statuses []WSServiceStatus
-
-	if ws.daemon.runner != nil {
-		for name, state := range ws.daemon.runner.Services {
-			state.Mu.Lock()
-			s := WSServiceStatus{
-				Name:    name,
-				Running: state.Running,
-				Port:    state.Service.Port,
-				Color:   state.Service.Color,
-				Icon:    state.Service.Icon,
-			}
-			state.Mu.Unlock()
-			statuses = append(statuses, s)
-		}
-	}
-
-	msg := WSStatusMessage{
-		Type:     "status",
-		Services: statuses,
-	}
-
-	data, _ := json.Marshal(msg)
+	data := ws.statusMessage()
 	_ = conn.WriteMessage(websocket.TextMessage, data)
 	_ = conn.Close()
 }
 
 // BroadcastLog sends a log entry to all connected WebSocket clients
 func (ws *WSServer) BroadcastLog(entry LogEntryData) {
-	msg := WSLogMessage{
-		Type:    "log",
-		Time:    entry.Time,
-		Service: entry.Service,
-		Level:   entry.Level,
-		Message: entry.Message,
+	data, err := json.Marshal(newWSLogMessage(entry))
+	if err != nil {
+		return
+	}
+
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	for client := range ws.clients {
+		client.sub.deliver(entry, func() { client.trySend(data) })
 	}
+}
 
-	data, err := json.Marshal(msg)
+// BroadcastStatus sends a changed service status to all connected WebSocket
+// clients
+func (ws *WSServer) BroadcastStatus(s ServiceStatus) {
+	data, err := json.Marshal(WSStatusChangedMessage{
+		Type:    "status_changed",
+		Service: newWSServiceStatus(s),
+	})
 	if err != nil {
 		return
 	}
 
+	ws.broadcast(data)
+}
+
+// BroadcastConfigChange reports a config reload to all connected WebSocket
+// clients
+func (ws *WSServer) BroadcastConfigChange(change ConfigChange) {
+	data, err := json.Marshal(WSConfigChangedMessage{
+		Type:         "config_changed",
+		ConfigChange: change,
+	})
+	if err != nil {
+		return
+	}
+
+	ws.broadcast(data)
+}
+
+// broadcast sends a message to all connected WebSocket clients
+func (ws *WSServer) broadcast(data []byte) {
 	ws.mu.RLock()
 	defer ws.mu.RUnlock()
 
 	for client := range ws.clients {
-		select {
-		case client.sendCh <- data:
-		default:
-			// Drop if buffer full
-		}
+		client.trySend(data)
+	}
+}
+
+// trySend queues a message unless the client's buffer is full
+func (c *wsClient) trySend(data []byte) {
+	select {
+	case c.sendCh <- data:
+	default:
+		// Drop if buffer full
 	}
 }
 
@@ -264,17 +385,18 @@ func (c *wsClient) readPump() {
 func (ws *WSServer) handleCommand(c *wsClient, cmd WSCommand) {
 	var resp WSResponse
 	resp.Type = "response"
+	r := ws.daemon.GetRunner()
 
 	switch cmd.Action {
 	case "restart":
 		if cmd.Service == "" {
 			resp.Error = "service name required"
-		} else if ws.daemon.runner == nil {
+		} else if r == nil {
 			resp.Error = "no services running"
-		} else if _, ok := ws.daemon.runner.Services[cmd.Service]; !ok {
+		} else if _, ok := r.States()[cmd.Service]; !ok {
 			resp.Error = "unknown service: " + cmd.Service
 		} else {
-			ws.daemon.runner.RestartService(cmd.Service)
+			r.RestartService(cmd.Service)
 			resp.Success = true
 			resp.Message = "restarting " + cmd.Service
 		}
@@ -282,19 +404,19 @@ func (ws *WSServer) handleCommand(c *wsClient, cmd WSCommand) {
 	case "stop":
 		if cmd.Service != "" {
 			// Stop specific service
-			if ws.daemon.runner == nil {
+			if r == nil {
 				resp.Error = "no services running"
-			} else if _, ok := ws.daemon.runner.Services[cmd.Service]; !ok {
+			} else if _, ok := r.States()[cmd.Service]; !ok {
 				resp.Error = "unknown service: " + cmd.Service
 			} else {
-				ws.daemon.runner.StopService(cmd.Service)
+				r.StopService(cmd.Service)
 				resp.Success = true
 				resp.Message = "stopped " + cmd.Service
 			}
 		} else {
 			// Stop all services
-			if ws.daemon.runner != nil {
-				ws.daemon.runner.Stop()
+			if r != nil {
+				r.Stop()
 				resp.Success = true
 				resp.Message = "stopping all services"
 			} else {
@@ -305,27 +427,18 @@ func (ws *WSServer) handleCommand(c *wsClient, cmd WSCommand) {
 	case "start":
 		if cmd.Service == "" {
 			resp.Error = "service name required"
-		} else if ws.daemon.runner == nil {
-			resp.Error = "no services running"
-		} else if state, ok := ws.daemon.runner.Services[cmd.Service]; !ok {
-			resp.Error = "unknown service: " + cmd.Service
+		} else if _, started, err := ws.daemon.startServices([]string{cmd.Service}, false); err != nil {
+			resp.Error = err.Error()
+		} else if !slices.Contains(started, cmd.Service) {
+			resp.Error = "service already running"
 		} else {
-			state.Mu.Lock()
-			isRunning := state.Running
-			state.Mu.Unlock()
-
-			if isRunning {
-				resp.Error = "service already running"
-			} else {
-				ws.daemon.runner.StartService(cmd.Service)
-				resp.Success = true
-				resp.Message = "starting " + cmd.Service
-			}
+			resp.Success = true
+			resp.Message = "starting " + cmd.Service
 		}
 
 	case "clear":
-		if ws.daemon.runner != nil {
-			ws.daemon.runner.ClearLogs(cmd.Service)
+		if r != nil {
+			r.ClearLogs(cmd.Service)
 			resp.Success = true
 			resp.Message = "logs cleared"
 		}
@@ -334,6 +447,42 @@ func (ws *WSServer) handleCommand(c *wsClient, cmd WSCommand) {
 		ws.sendStatus(c)
 		return
 
+	case "subscribe":
+		if filter, err := newLogFilter(cmd.Services, cmd.Level, cmd.Match); err != nil {
+			resp.Error = err.Error()
+		} else if cmd.After != nil {
+			// Replayed entries go out as a logs message before the response
+			c.sub.resume(filter, r, *cmd.After, maxReplayLines, func(logs []LogEntryData) {
+				c.trySend(newWSLogsMessage("history", logs, ""))
+			})
+			resp.Success = true
+			resp.Message = "subscribed"
+		} else {
+			c.sub.set(filter)
+			resp.Success = true
+			resp.Message = "subscribed"
+		}
+
+	case "history":
+		ws.sendHistory(c, cmd)
+		return
+
+	case "logs":
+		err := ws.sendLogs(c, cmd)
+		if err == nil {
+			return
+		}
+		resp.Error = err.Error()
+
+	case "reload":
+		if change, err := ws.daemon.reload(); err != nil {
+			resp.Error = err.Error()
+		} else {
+			ws.daemon.broadcastConfigChange(change)
+			resp.Success = true
+			resp.Message = "config reloaded"
+		}
+
 	default:
 		resp.Error = "unknown action: " + cmd.Action
 	}
@@ -342,23 +491,82 @@ func (ws *WSServer) handleCommand(c *wsClient, cmd WSCommand) {
 	c.sendCh <- data
 }
 
+// sendHistory answers the history action with the stored entries after the
+// given seq, or the recent ones, that pass the client's filter. Unlike a
+// replay on connect they may include entries the client got live; clients
+// tell them apart by seq.
+func (ws *WSServer) sendHistory(c *wsClient, cmd WSCommand) {
+	var after uint64
+	lines := cmd.Lines
+	if cmd.After != nil {
+		after = *cmd.After
+		if lines <= 0 {
+			lines = maxReplayLines
+		}
+	} else if lines <= 0 {
+		lines = defaultReplayLines
+	}
+
+	var logs []LogEntryData
+	if r := ws.daemon.GetRunner(); r != nil {
+		filter := c.sub.current()
+		found, _ := r.LinesAfter(after, func(line types.LogLine) bool {
+			return filter.matches(logEntryFromLine(line))
+		}, min(lines, maxReplayLines))
+		for _, line := range found {
+			logs = append(logs, logEntryFromLine(line))
+		}
+	}
+	c.sendCh <- newWSLogsMessage("history", logs, "")
+}
+
+// sendLogs answers the logs action
+func (ws *WSServer) sendLogs(c *wsClient, cmd WSCommand) error {
+	resp, err := ws.daemon.queryLogs(LogsRequest{
+		Service:  cmd.Service,
+		Services: cmd.Services,
+		Lines:    cmd.Lines,
+		Since:    cmd.Since,
+		Until:    cmd.Until,
+		Level:    cmd.Level,
+		Match:    cmd.Match,
+		Cursor:   cmd.Cursor,
+	})
+	if err != nil {
+		return err
+	}
+
+	c.sendCh <- newWSLogsMessage("logs", resp.Logs, resp.Cursor)
+	return nil
+}
+
+// newWSLogsMessage encodes stored entries for a logs or history message
+func newWSLogsMessage(msgType string, logs []LogEntryData, cursor string) []byte {
+	msg := WSLogsMessage{
+		Type:   msgType,
+		Logs:   make([]WSLogMessage, 0, len(logs)),
+		Cursor: cursor,
+	}
+	for _, entry := range logs {
+		msg.Logs = append(msg.Logs, newWSLogMessage(entry))
+	}
+
+	data, _ := json.Marshal(msg)
+	return data
+}
+
 func (ws *WSServer) sendStatus(c *wsClient) {
+	c.sendCh <- ws.statusMessage()
+}
+
+// statusMessage describes all services the way socket status responses do
+func (ws *WSServer) statusMessage() []byte {
 	var statuses []WSServiceStatus
 
-	if ws.daemon.runner != nil {
-		for name, state := range ws.daemon.runner.Services {
-			state.Mu.Lock()
-			s := WSServiceStatus{
-				Name:    name,
-				Running: state.Running,
-				Status:  string(state.Status),
-				Port:    state.Service.Port,
-				Color:   state.Service.Color,
-				Icon:    state.Service.Icon,
-				Type:    string(state.Service.GetEffectiveType()),
-			}
-			state.Mu.Unlock()
-			statuses = append(statuses, s)
+	if r := ws.daemon.GetRunner(); r != nil {
+		for name, state := range r.States() {
+			s, _ := ws.daemon.serviceStatus(name, state)
+			statuses = append(statuses, newWSServiceStatus(s))
 		}
 	}
 
@@ -368,7 +576,7 @@ func (ws *WSServer) sendStatus(c *wsClient) {
 	}
 
 	data, _ := json.Marshal(msg)
-	c.sendCh <- data
+	return data
 }
 
 func (c *wsClient) writePump() {
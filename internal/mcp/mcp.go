@@ -56,12 +56,12 @@ func (m *Server) registerTools() {
 
 	mcp.AddTool(m.server, &mcp.Tool{
 		Name:        "devir_start",
-		Description: "Start dev services. If no services specified, starts all default services. Use killPorts:true to auto-kill processes on conflicting ports.",
+		Description: "Start dev services. If no services specified, starts all default services. Services already running are left alone, so this can add a service to the running ones. Use killPorts:true to auto-kill processes on conflicting ports.",
 	}, m.handleStart)
 
 	mcp.AddTool(m.server, &mcp.Tool{
 		Name:        "devir_stop",
-		Description: "Stop all running services",
+		Description: "Stop services. If no services specified, stops all running services.",
 	}, m.handleStop)
 
 	mcp.AddTool(m.server, &mcp.Tool{
@@ -71,7 +71,7 @@ func (m *Server) registerTools() {
 
 	mcp.AddTool(m.server, &mcp.Tool{
 		Name:        "devir_logs",
-		Description: "Get recent logs from services",
+		Description: "Get logs of one or more services, merged by time and oldest first. Filter by time range, minimum level or a regex; pass the returned cursor to page back to older logs.",
 	}, m.handleLogs)
 
 	mcp.AddTool(m.server, &mcp.Tool{
@@ -83,6 +83,11 @@ func (m *Server) registerTools() {
 		Name:        "devir_clear_logs",
 		Description: "Clear logs from services",
 	}, m.handleClearLogs)
+
+	mcp.AddTool(m.server, &mcp.Tool{
+		Name:        "devir_reload",
+		Description: "Reload devir.yaml after editing it. Changed services are restarted, added default services started and removed services stopped; the others keep running.",
+	}, m.handleReload)
 }
 
 // Run starts the MCP server
@@ -93,9 +98,10 @@ func (m *Server) Run() error {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt)
 
+	// Services belong to the daemon; an embedded one is stopped by its
+	// owner once Run returns
 	go func() {
 		<-sigCh
-		_ = m.client.Stop()
 		cancel()
 	}()
 
@@ -132,6 +138,11 @@ type KillPortsOutput struct {
 type StartOutput struct {
 	Status   string   `json:"status"`
 	Services []string `json:"services"`
+	Started  []string `json:"started"`
+}
+
+type StopInput struct {
+	Services []string `json:"services,omitempty" jsonschema:"List of services to stop. If empty stops all services."`
 }
 
 type StopOutput struct {
@@ -139,17 +150,20 @@ type StopOutput struct {
 }
 
 type ServiceStatus struct {
-	Name     string  `json:"name"`
-	Running  bool    `json:"running"`
-	Port     int     `json:"port"`
-	Type     string  `json:"type"`     // service, oneshot, interval, http
-	Status   string  `json:"status"`   // running, completed, failed, waiting, stopped
-	LastRun  string  `json:"lastRun"`  // ISO timestamp
-	NextRun  string  `json:"nextRun"`  // ISO timestamp (for interval)
-	ExitCode int     `json:"exitCode"` // last exit code
-	RunCount int     `json:"runCount"` // number of runs
-	CPU      float64 `json:"cpu"`      // CPU percentage
-	Memory   uint64  `json:"memory"`   // Memory in bytes (RSS)
+	Name       string  `json:"name"`
+	Running    bool    `json:"running"`
+	Port       int     `json:"port"`
+	Type       string  `json:"type"`                 // service, oneshot, interval, http
+	Status     string  `json:"status"`               // running, starting, healthy, unhealthy, restarting, crashloop, completed, failed, waiting, stopped
+	LastRun    string  `json:"lastRun"`              // ISO timestamp
+	NextRun    string  `json:"nextRun"`              // ISO timestamp (for interval)
+	ExitCode   int     `json:"exitCode"`             // last exit code
+	RunCount   int     `json:"runCount"`             // number of runs
+	Restarts   int     `json:"restarts"`             // consecutive automatic restarts
+	CrashLoop  bool    `json:"crashLoop"`            // restarting repeatedly without staying up
+	StopResult string  `json:"stopResult,omitempty"` // how the last stop ended: graceful, killed
+	CPU        float64 `json:"cpu"`                  // CPU percentage
+	Memory     uint64  `json:"memory"`               // Memory in bytes (RSS)
 }
 
 type StatusOutput struct {
@@ -157,18 +171,27 @@ type StatusOutput struct {
 }
 
 type LogsInput struct {
-	Service string `json:"service,omitempty" jsonschema:"Service name to get logs from. If empty returns all logs."`
-	Lines   int    `json:"lines,omitempty" jsonschema:"Number of log lines to return. Default 100."`
+	Service  string   `json:"service,omitempty" jsonschema:"Service name to get logs from. If empty and services is empty returns all logs."`
+	Services []string `json:"services,omitempty" jsonschema:"Services to get logs from. If empty returns all logs."`
+	Lines    int      `json:"lines,omitempty" jsonschema:"Number of log lines to return. Default 100."`
+	Since    string   `json:"since,omitempty" jsonschema:"Only logs at or after this time: RFC 3339 (2024-05-01T10:00:00Z) or a duration ago (10m)."`
+	Until    string   `json:"until,omitempty" jsonschema:"Only logs before this time: RFC 3339 or a duration ago."`
+	Level    string   `json:"level,omitempty" jsonschema:"Minimum level: debug, info, warn or error."`
+	Match    string   `json:"match,omitempty" jsonschema:"Regex the message must match."`
+	Cursor   string   `json:"cursor,omitempty" jsonschema:"Cursor from a previous call, to get the logs before those it returned."`
 }
 
 type LogEntry struct {
-	Service string `json:"service"`
-	Level   string `json:"level"`
-	Message string `json:"message"`
+	Time    string            `json:"time"`
+	Service string            `json:"service"`
+	Level   string            `json:"level"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
 }
 
 type LogsOutput struct {
-	Logs []LogEntry `json:"logs"`
+	Logs   []LogEntry `json:"logs"`
+	Cursor string     `json:"cursor,omitempty"` // set if older logs remain
 }
 
 type RestartInput struct {
@@ -189,6 +212,14 @@ type ClearLogsOutput struct {
 	Service string `json:"service,omitempty"`
 }
 
+type ReloadOutput struct {
+	Status  string   `json:"status"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+	Started []string `json:"started"`
+}
+
 // Handlers
 
 func (m *Server) handleCheckPorts(ctx context.Context, req *mcp.CallToolRequest, input struct{}) (*mcp.CallToolResult, CheckPortsOutput, error) {
@@ -225,30 +256,22 @@ func (m *Server) handleKillPorts(ctx context.Context, req *mcp.CallToolRequest,
 }
 
 func (m *Server) handleStart(ctx context.Context, req *mcp.CallToolRequest, input StartInput) (*mcp.CallToolResult, StartOutput, error) {
-	services := input.Services
-	if len(services) == 0 {
-		services = m.cfg.Defaults
-	}
-
-	for _, name := range services {
-		if _, ok := m.cfg.Services[name]; !ok {
-			return nil, StartOutput{}, fmt.Errorf("unknown service: %s", name)
-		}
-	}
-
-	started, err := m.client.StartAndWait(services, input.KillPorts, 10*time.Second)
+	// The daemon picks the defaults and validates against its config, which
+	// may have been reloaded since we started
+	resp, err := m.client.StartAndWait(input.Services, input.KillPorts, 10*time.Second)
 	if err != nil {
 		return nil, StartOutput{}, err
 	}
 
 	return nil, StartOutput{
 		Status:   "started",
-		Services: started,
+		Services: resp.Services,
+		Started:  resp.Started,
 	}, nil
 }
 
-func (m *Server) handleStop(ctx context.Context, req *mcp.CallToolRequest, input struct{}) (*mcp.CallToolResult, StopOutput, error) {
-	if err := m.client.Stop(); err != nil {
+func (m *Server) handleStop(ctx context.Context, req *mcp.CallToolRequest, input StopInput) (*mcp.CallToolResult, StopOutput, error) {
+	if err := m.client.StopSync(input.Services, 30*time.Second); err != nil {
 		return nil, StopOutput{}, err
 	}
 	return nil, StopOutput{Status: "stopped"}, nil
@@ -263,17 +286,20 @@ func (m *Server) handleStatus(ctx context.Context, req *mcp.CallToolRequest, inp
 	result := make([]ServiceStatus, 0, len(statuses))
 	for _, s := range statuses {
 		result = append(result, ServiceStatus{
-			Name:     s.Name,
-			Running:  s.Running,
-			Port:     s.Port,
-			Type:     s.Type,
-			Status:   s.Status,
-			LastRun:  s.LastRun,
-			NextRun:  s.NextRun,
-			ExitCode: s.ExitCode,
-			RunCount: s.RunCount,
-			CPU:      s.CPU,
-			Memory:   s.Memory,
+			Name:       s.Name,
+			Running:    s.Running,
+			Port:       s.Port,
+			Type:       s.Type,
+			Status:     s.Status,
+			LastRun:    s.LastRun,
+			NextRun:    s.NextRun,
+			ExitCode:   s.ExitCode,
+			RunCount:   s.RunCount,
+			Restarts:   s.Restarts,
+			CrashLoop:  s.CrashLoop,
+			StopResult: s.StopResult,
+			CPU:        s.CPU,
+			Memory:     s.Memory,
 		})
 	}
 
@@ -281,26 +307,61 @@ func (m *Server) handleStatus(ctx context.Context, req *mcp.CallToolRequest, inp
 }
 
 func (m *Server) handleLogs(ctx context.Context, req *mcp.CallToolRequest, input LogsInput) (*mcp.CallToolResult, LogsOutput, error) {
-	lines := input.Lines
-	if lines <= 0 {
-		lines = 100
+	query := daemon.LogsRequest{
+		Service:  input.Service,
+		Services: input.Services,
+		Lines:    input.Lines,
+		Level:    input.Level,
+		Match:    input.Match,
+		Cursor:   input.Cursor,
+	}
+	var err error
+	if query.Since, err = parseTime(input.Since); err != nil {
+		return nil, LogsOutput{}, fmt.Errorf("invalid since: %w", err)
+	}
+	if query.Until, err = parseTime(input.Until); err != nil {
+		return nil, LogsOutput{}, fmt.Errorf("invalid until: %w", err)
 	}
 
-	logs, err := m.client.LogsSync(input.Service, lines, 5*time.Second)
+	filtered := len(query.Services) > 0 || !query.Since.IsZero() || !query.Until.IsZero() ||
+		query.Level != "" || query.Match != "" || query.Cursor != ""
+	if filtered && !m.client.HasCapability("log_query") {
+		return nil, LogsOutput{}, fmt.Errorf("the running devir daemon is too old to filter logs; restart it")
+	}
+
+	resp, err := m.client.QueryLogs(query, 5*time.Second)
 	if err != nil {
 		return nil, LogsOutput{}, err
 	}
 
-	var result []LogEntry
-	for _, l := range logs {
+	result := make([]LogEntry, 0, len(resp.Logs))
+	for _, l := range resp.Logs {
 		result = append(result, LogEntry{
+			Time:    l.Time.Format(time.RFC3339Nano),
 			Service: l.Service,
 			Level:   l.Level,
 			Message: l.Message,
+			Fields:  l.Fields,
 		})
 	}
 
-	return nil, LogsOutput{Logs: result}, nil
+	return nil, LogsOutput{Logs: result, Cursor: resp.Cursor}, nil
+}
+
+// parseTime accepts an RFC 3339 time or a duration before now. Empty means
+// no time.
+func parseTime(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither an RFC 3339 time nor a duration", s)
+	}
+	return time.Now().Add(-d), nil
 }
 
 func (m *Server) handleRestart(ctx context.Context, req *mcp.CallToolRequest, input RestartInput) (*mcp.CallToolResult, RestartOutput, error) {
@@ -328,3 +389,18 @@ func (m *Server) handleClearLogs(ctx context.Context, req *mcp.CallToolRequest,
 		Service: input.Service,
 	}, nil
 }
+
+func (m *Server) handleReload(ctx context.Context, req *mcp.CallToolRequest, input struct{}) (*mcp.CallToolResult, ReloadOutput, error) {
+	change, err := m.client.Reload(30 * time.Second)
+	if err != nil {
+		return nil, ReloadOutput{}, err
+	}
+
+	return nil, ReloadOutput{
+		Status:  "reloaded",
+		Added:   change.Added,
+		Removed: change.Removed,
+		Changed: change.Changed,
+		Started: change.Started,
+	}, nil
+}
@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"regexp"
+	"strings"
+
+	"devir/internal/config"
+)
+
+// zeroErrorsPattern matches summaries like "0 errors" or "no failures",
+// which mention errors without being one
+var zeroErrorsPattern = regexp.MustCompile(`(?i)\b(0|no) (errors?|failures?|failed)\b`)
+
+// classifyLevel returns the level of a plain text output line: the first
+// matching levels rule (including the preset's), then stderr, then a guess
+// based on the content
+func classifyLevel(svc config.Service, text string, isStderr bool) string {
+	for _, rule := range svc.Levels {
+		if rule.Matches(text) {
+			return rule.Level
+		}
+	}
+
+	if isStderr && (svc.StderrAsError == nil || *svc.StderrAsError) {
+		return "error"
+	}
+
+	lowerText := strings.ToLower(text)
+	switch {
+	case zeroErrorsPattern.MatchString(text):
+		return "info"
+	case strings.Contains(lowerText, "error") || strings.Contains(lowerText, "fail"):
+		return "error"
+	case strings.Contains(lowerText, "warn"):
+		return "warn"
+	case strings.Contains(lowerText, "debug"):
+		return "debug"
+	default:
+		return "info"
+	}
+}
@@ -0,0 +1,339 @@
+package runner
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"devir/internal/config"
+	"devir/internal/types"
+)
+
+// logTimeFormat is the fixed-width timestamp at the start of each log file line
+const logTimeFormat = "2006-01-02T15:04:05.000000000Z07:00"
+
+// rotateTimeFormat is the timestamp in rotated file names
+const rotateTimeFormat = "20060102T150405.000"
+
+// logFile writes a service's output to <dir>/<service>.log and rotates it
+// when it grows too large or too old. Rotated files are named
+// <service>-<time>.log, optionally gzipped, and pruned to MaxFiles.
+type logFile struct {
+	mu      sync.Mutex
+	name    string
+	dir     string
+	cfg     config.LogsConfig
+	file    *os.File
+	size    int64
+	created time.Time // time of the first line in the current file
+}
+
+func newLogFile(rootDir, name string, cfg *config.LogsConfig) *logFile {
+	dir := cfg.Dir
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(rootDir, dir)
+	}
+	return &logFile{name: name, dir: dir, cfg: *cfg}
+}
+
+func (l *logFile) path() string {
+	return filepath.Join(l.dir, l.name+".log")
+}
+
+// write appends a line, rotating the file first if needed
+func (l *logFile) write(line types.LogLine) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.file == nil {
+		if err := l.open(); err != nil {
+			return err
+		}
+	}
+
+	text := formatLogFileLine(line)
+	if l.size > 0 && l.needsRotate(line.Timestamp, len(text)) {
+		if err := l.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := io.WriteString(l.file, text)
+	l.size += int64(n)
+	if l.created.IsZero() {
+		l.created = line.Timestamp
+	}
+	return err
+}
+
+func (l *logFile) needsRotate(now time.Time, next int) bool {
+	if l.size+int64(next) > int64(l.cfg.MaxSize)*1024*1024 {
+		return true
+	}
+	return l.cfg.MaxAge > 0 && !l.created.IsZero() && now.Sub(l.created) >= l.cfg.MaxAge
+}
+
+// open opens the current log file for appending, picking up its size and age
+func (l *logFile) open() error {
+	if err := os.MkdirAll(l.dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	l.file = f
+	l.size = info.Size()
+	l.created = time.Time{}
+	if l.size > 0 {
+		l.created = firstLogTime(l.path())
+	}
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one. Compression and
+// pruning of old files happen in the background.
+func (l *logFile) rotate() error {
+	_ = l.file.Close()
+	l.file = nil
+
+	rotated := filepath.Join(l.dir, fmt.Sprintf("%s-%s.log", l.name, time.Now().Format(rotateTimeFormat)))
+	if err := os.Rename(l.path(), rotated); err != nil {
+		return err
+	}
+
+	compress := l.cfg.Compress != nil && *l.cfg.Compress
+	go func() {
+		if compress {
+			_ = gzipFile(rotated)
+		}
+		l.prune()
+	}()
+
+	return l.open()
+}
+
+// prune removes the oldest rotated files beyond MaxFiles
+func (l *logFile) prune() {
+	files := l.rotatedFiles()
+	for len(files) > l.cfg.MaxFiles {
+		_ = os.Remove(files[len(files)-1])
+		files = files[:len(files)-1]
+	}
+}
+
+// rotatedFiles returns the rotated files of the service, newest first
+func (l *logFile) rotatedFiles() []string {
+	matches, _ := filepath.Glob(filepath.Join(l.dir, l.name+"-*.log*"))
+
+	var files []string
+	for _, m := range matches {
+		// Skip other services whose name starts with ours, and temp files
+		stamp, ext, _ := strings.Cut(strings.TrimPrefix(filepath.Base(m), l.name+"-"), ".log")
+		if _, err := time.Parse(rotateTimeFormat, stamp); err != nil || (ext != "" && ext != ".gz") {
+			continue
+		}
+		// A .log being compressed may briefly exist next to its .log.gz
+		if ext == "" && slices.Contains(matches, m+".gz") {
+			continue
+		}
+		files = append(files, m)
+	}
+
+	// The timestamp in the name sorts chronologically
+	sort.Sort(sort.Reverse(sort.StringSlice(files)))
+	return files
+}
+
+func (l *logFile) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file != nil {
+		_ = l.file.Close()
+		l.file = nil
+	}
+}
+
+// tail returns up to n lines written before the given time, reading rotated
+// files as far back as needed. Lines are returned oldest first.
+func (l *logFile) tail(n int, before time.Time) []types.LogLine {
+	if n <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	files := append([]string{l.path()}, l.rotatedFiles()...)
+	l.mu.Unlock()
+
+	var result []types.LogLine
+	for _, path := range files {
+		lines := readLogFile(path, before)
+		if len(lines)+len(result) >= n {
+			lines = lines[len(lines)+len(result)-n:]
+			return append(lines, result...)
+		}
+		result = append(lines, result...)
+	}
+	return result
+}
+
+// readLogFile reads the lines of a (possibly gzipped) log file that were
+// written before the given time
+func readLogFile(path string, before time.Time) []types.LogLine {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil
+		}
+		defer func() { _ = gz.Close() }()
+		r = gz
+	}
+
+	var lines []types.LogLine
+	var last *types.LogLine // entry that continuation lines belong to, nil if skipped
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if cont, ok := strings.CutPrefix(text, "\t"); ok {
+			if last != nil {
+				last.Text += "\n" + cont
+			}
+			continue
+		}
+
+		line, ok := parseLogFileLine(text)
+		if !ok || !line.Timestamp.Before(before) {
+			last = nil
+			continue
+		}
+		lines = append(lines, line)
+		last = &lines[len(lines)-1]
+	}
+	return lines
+}
+
+// logFileStreams are the stream names used in log files
+var logFileStreams = map[string]string{
+	types.StreamStdout: "out",
+	types.StreamStderr: "err",
+	types.StreamDevir:  "sys",
+}
+
+// formatLogFileLine formats a line as "<time> out|err|sys <level> <text>".
+// Further lines of a multiline entry follow, each indented with a tab.
+func formatLogFileLine(line types.LogLine) string {
+	stream := logFileStreams[line.Stream]
+	if stream == "" {
+		stream = "out"
+		if line.IsError {
+			stream = "err"
+		}
+	}
+	text := line.Text
+	if len(line.Fields) > 0 {
+		text += " " + FormatFields(line.Fields)
+	}
+	text = strings.ReplaceAll(text, "\n", "\n\t")
+	return fmt.Sprintf("%s %s %-5s %s\n", line.Timestamp.Format(logTimeFormat), stream, line.Level, text)
+}
+
+func parseLogFileLine(s string) (types.LogLine, bool) {
+	ts, rest, ok := strings.Cut(s, " ")
+	if !ok {
+		return types.LogLine{}, false
+	}
+	t, err := time.Parse(logTimeFormat, ts)
+	if err != nil {
+		return types.LogLine{}, false
+	}
+	stream, rest, _ := strings.Cut(rest, " ")
+	level, text, _ := strings.Cut(rest, " ")
+
+	line := types.LogLine{
+		Text:      strings.TrimLeft(text, " "),
+		Timestamp: t,
+		Level:     level,
+		Stream:    types.StreamStdout,
+	}
+	switch stream {
+	case "err":
+		line.Stream = types.StreamStderr
+		line.IsError = true
+	case "sys":
+		line.Stream = types.StreamDevir
+		line.IsError = level == "error"
+	}
+	return line, true
+}
+
+// firstLogTime returns the timestamp of the first line of a log file
+func firstLogTime(path string) time.Time {
+	f, err := os.Open(path)
+	if err != nil {
+		return time.Time{}
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	if scanner.Scan() {
+		if line, ok := parseLogFileLine(scanner.Text()); ok {
+			return line.Timestamp
+		}
+	}
+	return time.Time{}
+}
+
+// gzipFile compresses path to path.gz and removes the original
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
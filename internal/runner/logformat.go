@@ -0,0 +1,223 @@
+package runner
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"devir/internal/config"
+)
+
+// Keys tried when log_keys doesn't name one explicitly
+var (
+	defaultLevelKeys   = []string{"level", "lvl", "severity"}
+	defaultMessageKeys = []string{"msg", "message"}
+	defaultTimeKeys    = []string{"time", "ts", "timestamp"}
+)
+
+// structuredLine is a log line parsed according to the service's log_format
+type structuredLine struct {
+	Level   string // info, warn, error, debug; empty if the line has none
+	Message string
+	Time    time.Time // zero if the line has none
+	Fields  map[string]string
+}
+
+// parseStructured parses a json or logfmt line. It returns false for text
+// services and for lines that are not in the configured format (e.g. a
+// startup banner), which are then handled as plain text.
+func parseStructured(svc config.Service, text string) (structuredLine, bool) {
+	var fields map[string]string
+	switch svc.LogFormat {
+	case config.LogFormatJSON:
+		fields = parseJSONFields(text)
+	case config.LogFormatLogfmt:
+		fields = parseLogfmt(text)
+	}
+	if fields == nil {
+		return structuredLine{}, false
+	}
+
+	var line structuredLine
+	if v, ok := takeField(fields, svc.LogKeys.Message, defaultMessageKeys); ok {
+		line.Message = v
+	} else {
+		// Keep the whole line rather than showing an empty message
+		return structuredLine{}, false
+	}
+	if v, ok := takeField(fields, svc.LogKeys.Level, defaultLevelKeys); ok {
+		line.Level = normalizeLevel(v)
+	}
+	if v, ok := takeField(fields, svc.LogKeys.Time, defaultTimeKeys); ok {
+		line.Time = parseLogTime(v)
+	}
+	if len(fields) > 0 {
+		line.Fields = fields
+	}
+	return line, true
+}
+
+// takeField removes and returns the configured key, or the first default key
+// present in fields
+func takeField(fields map[string]string, key string, defaults []string) (string, bool) {
+	keys := defaults
+	if key != "" {
+		keys = []string{key}
+	}
+	for _, k := range keys {
+		if v, ok := fields[k]; ok {
+			delete(fields, k)
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// parseJSONFields decodes a JSON object line. Non-string values are kept in
+// their compact JSON form.
+func parseJSONFields(text string) map[string]string {
+	if !strings.HasPrefix(text, "{") {
+		return nil
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(text), &raw); err != nil {
+		return nil
+	}
+
+	fields := make(map[string]string, len(raw))
+	for k, v := range raw {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			fields[k] = s
+		} else {
+			fields[k] = string(v)
+		}
+	}
+	return fields
+}
+
+// parseLogfmt decodes a line of key=value pairs. Values may be double quoted.
+// Lines with a bare word are not logfmt.
+func parseLogfmt(text string) map[string]string {
+	fields := make(map[string]string)
+	s := text
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 || strings.ContainsAny(s[:eq], " \t\"") {
+			return nil
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil
+			}
+			unquoted, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return nil
+			}
+			value, s = unquoted, s[end+1:]
+		} else {
+			end := strings.IndexAny(s, " \t")
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+		fields[key] = value
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// normalizeLevel maps level names and pino's numeric levels to the levels
+// used in the UI
+func normalizeLevel(v string) string {
+	if n, err := strconv.Atoi(v); err == nil {
+		switch {
+		case n >= 50:
+			return "error"
+		case n >= 40:
+			return "warn"
+		case n >= 30:
+			return "info"
+		default:
+			return "debug"
+		}
+	}
+
+	switch strings.ToLower(v) {
+	case "error", "err", "fatal", "panic", "dpanic", "critical", "crit", "alert", "emerg":
+		return "error"
+	case "warn", "warning":
+		return "warn"
+	case "debug", "trace":
+		return "debug"
+	default:
+		return "info"
+	}
+}
+
+// parseLogTime parses RFC 3339 timestamps and Unix epochs in seconds,
+// milliseconds or nanoseconds
+func parseLogTime(v string) time.Time {
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999"} {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t
+		}
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f <= 0 {
+		return time.Time{}
+	}
+	switch {
+	case f > 1e17:
+		return time.Unix(0, int64(f))
+	case f > 1e11:
+		return time.UnixMilli(int64(f))
+	default:
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9))
+	}
+}
+
+// FormatFields renders fields as sorted key=value pairs
+func FormatFields(fields map[string]string) string {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		v := fields[k]
+		if v == "" || strings.ContainsAny(v, " \t\"=") {
+			v = strconv.Quote(v)
+		}
+		parts[i] = fmt.Sprintf("%s=%s", k, v)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,78 @@
+package runner
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"devir/internal/config"
+)
+
+// lineGrouper collects the output lines of one stream of a service into log
+// entries according to the service's multiline rule. Without a rule every
+// line is its own entry.
+type lineGrouper struct {
+	r       *Runner
+	service string
+	isError bool
+	ml      *config.MultilineConfig
+
+	mu    sync.Mutex
+	lines []string
+	timer *time.Timer
+}
+
+func (r *Runner) newLineGrouper(service string, svc config.Service, isError bool) *lineGrouper {
+	return &lineGrouper{r: r, service: service, isError: isError, ml: svc.Multiline}
+}
+
+// add handles the next line of output
+func (g *lineGrouper) add(text string) {
+	if g.ml == nil {
+		g.r.processLine(g.service, text, g.isError, "")
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	plain := ansiPattern.ReplaceAllString(cleanLine(text), "")
+	blank := strings.TrimSpace(plain) == ""
+
+	if len(g.lines) > 0 && len(g.lines) < g.ml.MaxLines && (blank || g.ml.Continues(plain)) {
+		g.lines = append(g.lines, text)
+	} else {
+		g.flushLocked()
+		if blank {
+			return
+		}
+		g.lines = append(g.lines, text)
+	}
+
+	// The last line of an entry is only known once the next entry starts,
+	// so a quiet stream flushes after the timeout
+	if g.timer == nil {
+		g.timer = time.AfterFunc(g.ml.Timeout, g.flush)
+	} else {
+		g.timer.Reset(g.ml.Timeout)
+	}
+}
+
+// flush emits the pending entry, if any
+func (g *lineGrouper) flush() {
+	if g.ml == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.flushLocked()
+}
+
+func (g *lineGrouper) flushLocked() {
+	if len(g.lines) == 0 {
+		return
+	}
+	text := strings.Join(g.lines, "\n")
+	g.lines = g.lines[:0]
+	g.r.processLine(g.service, text, g.isError, "")
+}
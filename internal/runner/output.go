@@ -0,0 +1,129 @@
+package runner
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"devir/internal/config"
+)
+
+// Size of the pseudo-terminal of tty services
+const (
+	ptyCols = 160
+	ptyRows = 48
+)
+
+// outputDrainDelay is how long to wait for the rest of the output once a
+// process has exited. Children it left behind may hold the streams open.
+const outputDrainDelay = 500 * time.Millisecond
+
+// processOutput holds the streams of a started process
+type processOutput struct {
+	stdin  io.Writer // nil if the process gets no input
+	stdout *os.File  // pseudo-terminal master for tty services
+	stderr *os.File  // nil under a pseudo-terminal, which merges both streams
+}
+
+// startProcess starts cmd with its output captured. Services with tty set
+// run under a pseudo-terminal so they keep their colors and interactivity.
+// Long-running services get a stdin pipe for attached clients; other
+// commands read from the null device as before.
+func startProcess(cmd *exec.Cmd, svc config.Service) (*processOutput, error) {
+	if svc.TTY {
+		tty, err := startPTY(cmd, ptyCols, ptyRows)
+		if err != nil {
+			return nil, err
+		}
+		return &processOutput{stdin: tty, stdout: tty}, nil
+	}
+
+	SetSysProcAttr(cmd)
+	var stdin io.Writer
+	if svc.IsLongRunning() {
+		// Wait closes the pipe once the process exits
+		pipe, err := cmd.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		stdin = pipe
+	}
+	// Unlike StdoutPipe, the read ends stay open after Wait, so the exit is
+	// seen even while children still write to them
+	stdout, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, stderrW, err := os.Pipe()
+	if err != nil {
+		_ = stdout.Close()
+		_ = stdoutW.Close()
+		return nil, err
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	err = cmd.Start()
+	// Only the process keeps the write ends, so the reads end when it and
+	// its children are done
+	_ = stdoutW.Close()
+	_ = stderrW.Close()
+	if err != nil {
+		_ = stdout.Close()
+		_ = stderr.Close()
+		return nil, err
+	}
+	return &processOutput{stdin: stdin, stdout: stdout, stderr: stderr}, nil
+}
+
+// readOutput processes the output of a process in the background until its
+// streams are closed. The returned channel is closed when that is done.
+func (r *Runner) readOutput(name string, svc config.Service, out *processOutput) <-chan struct{} {
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for _, stream := range []struct {
+		pipe    *os.File
+		isError bool
+	}{{out.stdout, false}, {out.stderr, true}} {
+		if stream.pipe == nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.scanOutput(name, svc, stream.pipe, stream.isError)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		_ = out.stdout.Close()
+		if out.stderr != nil {
+			_ = out.stderr.Close()
+		}
+		close(done)
+	}()
+	return done
+}
+
+// waitOutput waits for the output of an exited process to be read, but not
+// longer than outputDrainDelay. Whatever comes later is still logged.
+func waitOutput(drained <-chan struct{}) {
+	select {
+	case <-drained:
+	case <-time.After(outputDrainDelay):
+	}
+}
+
+// scanOutput reads a stdout or stderr pipe until it is closed
+func (r *Runner) scanOutput(name string, svc config.Service, pipe io.Reader, isError bool) {
+	g := r.newLineGrouper(name, svc, isError)
+	defer g.flush()
+
+	scanner := bufio.NewScanner(pipe)
+	scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
+	for scanner.Scan() {
+		g.add(scanner.Text())
+	}
+}
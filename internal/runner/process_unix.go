@@ -4,10 +4,13 @@ package runner
 
 import (
 	"fmt"
+	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 	"syscall"
+
+	"github.com/creack/pty"
 )
 
 // KillProcess kills a process by PID
@@ -20,6 +23,26 @@ func KillProcessGroup(pid int) {
 	_ = syscall.Kill(-pid, syscall.SIGTERM)
 }
 
+// stopSignals maps the stop_signal names accepted in the config
+var stopSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+// SignalProcessGroup sends the named signal (SIGTERM if unknown) to a process group
+func SignalProcessGroup(pid int, signal string) {
+	sig, ok := stopSignals[signal]
+	if !ok {
+		sig = syscall.SIGTERM
+	}
+	_ = syscall.Kill(-pid, sig)
+}
+
 // ForceKillProcessGroup force kills a process group
 func ForceKillProcessGroup(pid int) {
 	_ = syscall.Kill(-pid, syscall.SIGKILL)
@@ -112,3 +135,15 @@ func GetProcessMetrics(pid int) (ProcessMetrics, error) {
 		Memory: totalMemory,
 	}, nil
 }
+
+// ShellCommand creates a command that runs the given string through the shell
+func ShellCommand(command string) *exec.Cmd {
+	return exec.Command("sh", "-c", command)
+}
+
+// startPTY starts cmd under a new pseudo-terminal and returns its master
+// side. The process leads a new session, and so its own process group.
+func startPTY(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	size := &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows)}
+	return pty.StartWithAttrs(cmd, size, &syscall.SysProcAttr{Setsid: true, Setctty: true})
+}
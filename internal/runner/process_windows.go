@@ -26,6 +26,11 @@ func KillProcessGroup(pid int) {
 	}
 }
 
+// SignalProcessGroup kills a process (no signals or process groups on Windows)
+func SignalProcessGroup(pid int, signal string) {
+	KillProcessGroup(pid)
+}
+
 // ForceKillProcessGroup force kills a process (no process groups on Windows)
 func ForceKillProcessGroup(pid int) {
 	proc, err := os.FindProcess(pid)
@@ -81,3 +86,13 @@ func GetProcessMetrics(pid int) (ProcessMetrics, error) {
 	// Windows implementation not yet available
 	return ProcessMetrics{}, nil
 }
+
+// ShellCommand creates a command that runs the given string through cmd.exe
+func ShellCommand(command string) *exec.Cmd {
+	return exec.Command("cmd", "/C", command)
+}
+
+// startPTY is not supported on Windows
+func startPTY(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	return nil, fmt.Errorf("tty is not supported on Windows")
+}
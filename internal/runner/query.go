@@ -0,0 +1,265 @@
+package runner
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"devir/internal/types"
+)
+
+// queryFileLines caps how many lines a log query reads from the log files of
+// one service
+const queryFileLines = 10000
+
+// LogQuery selects stored log lines across services
+type LogQuery struct {
+	Services []string                 // empty = all
+	Since    time.Time                // zero = no lower bound
+	Until    time.Time                // zero = no upper bound, else only lines before it
+	Match    func(types.LogLine) bool // nil = every line
+	Limit    int
+	Cursor   *LogCursor // continue with the lines older than this, nil = newest
+}
+
+// LogCursor marks where a log query stopped. Lines are ordered by time and
+// then service name, so Skip counts the lines of Service at exactly Time that
+// were already looked at.
+type LogCursor struct {
+	Time    time.Time
+	Service string
+	Skip    int
+}
+
+// QueryLogs returns the newest lines that match the query, merged across
+// services and ordered oldest first. Lines that left the memory buffer are
+// read from the log files. The cursor continues with older lines; it is nil
+// when there are none.
+func (r *Runner) QueryLogs(q LogQuery) ([]types.LogLine, *LogCursor) {
+	states := r.States()
+	var sources []*logSource
+	for _, name := range r.Order() {
+		if len(q.Services) > 0 && !slices.Contains(q.Services, name) {
+			continue
+		}
+		if state := states[name]; state != nil {
+			sources = append(sources, newLogSource(state, q.Since))
+		}
+	}
+
+	// Skip what is newer than the requested range
+	for _, src := range sources {
+		for {
+			line, ok := src.peek()
+			if !ok {
+				break
+			}
+			if !q.Until.IsZero() && !line.Timestamp.Before(q.Until) {
+				src.pop()
+				continue
+			}
+			if q.Cursor != nil && newerThanCursor(line, q.Cursor) {
+				src.pop()
+				continue
+			}
+			break
+		}
+		if q.Cursor != nil && src.name == q.Cursor.Service {
+			for range q.Cursor.Skip {
+				if line, ok := src.peek(); !ok || !line.Timestamp.Equal(q.Cursor.Time) {
+					break
+				}
+				src.pop()
+			}
+		}
+	}
+
+	var pos LogCursor
+	if q.Cursor != nil {
+		pos = *q.Cursor
+	}
+
+	var lines []types.LogLine
+	for len(lines) < q.Limit {
+		src, line := newestLine(sources)
+		if src == nil || (!q.Since.IsZero() && line.Timestamp.Before(q.Since)) {
+			return reversed(lines), nil
+		}
+		src.pop()
+
+		if line.Timestamp.Equal(pos.Time) && line.Service == pos.Service {
+			pos.Skip++
+		} else {
+			pos = LogCursor{Time: line.Timestamp, Service: line.Service, Skip: 1}
+		}
+
+		if q.Match == nil || q.Match(line) {
+			lines = append(lines, line)
+		}
+	}
+
+	// Only report a cursor if there is something left to page through
+	if src, line := newestLine(sources); src == nil || (!q.Since.IsZero() && line.Timestamp.Before(q.Since)) {
+		return reversed(lines), nil
+	}
+	return reversed(lines), &pos
+}
+
+// newerThanCursor reports whether a line comes after the cursor position in
+// the query order
+func newerThanCursor(line types.LogLine, c *LogCursor) bool {
+	if !line.Timestamp.Equal(c.Time) {
+		return line.Timestamp.After(c.Time)
+	}
+	return line.Service > c.Service
+}
+
+// newestLine returns the source with the newest next line, breaking ties by
+// service name
+func newestLine(sources []*logSource) (*logSource, types.LogLine) {
+	var best *logSource
+	var bestLine types.LogLine
+	for _, src := range sources {
+		line, ok := src.peek()
+		if !ok {
+			continue
+		}
+		if best == nil || line.Timestamp.After(bestLine.Timestamp) ||
+			(line.Timestamp.Equal(bestLine.Timestamp) && line.Service > bestLine.Service) {
+			best, bestLine = src, line
+		}
+	}
+	return best, bestLine
+}
+
+func reversed(lines []types.LogLine) []types.LogLine {
+	slices.Reverse(lines)
+	return lines
+}
+
+// logSource walks the stored lines of one service from the newest to the
+// oldest: first the memory buffer, then the log files
+type logSource struct {
+	name  string
+	state *ServiceState
+	since time.Time
+
+	mem       []types.LogLine
+	memNext   int       // index of the next memory line, -1 when done
+	memOldest time.Time // of the whole buffer, zero if it was empty
+
+	file     []types.LogLine // read once the memory buffer is used up
+	fileRead bool
+	fileNext int
+}
+
+func newLogSource(state *ServiceState, since time.Time) *logSource {
+	snap := state.Logs.Snapshot()
+	src := &logSource{
+		name:  state.Name,
+		state: state,
+		since: since,
+	}
+	if snap.Len() > 0 {
+		src.memOldest = snap.At(0).Timestamp
+	}
+	// Only the lines in the requested range are copied
+	if since.IsZero() {
+		src.mem = snap.Lines()
+	} else {
+		src.mem = snap.Since(since)
+	}
+	src.memNext = len(src.mem) - 1
+	return src
+}
+
+func (s *logSource) peek() (types.LogLine, bool) {
+	if s.memNext >= 0 {
+		return s.mem[s.memNext], true
+	}
+	if !s.fileRead {
+		s.readFile()
+	}
+	if s.fileNext >= 0 {
+		return s.file[s.fileNext], true
+	}
+	return types.LogLine{}, false
+}
+
+func (s *logSource) pop() {
+	if s.memNext >= 0 {
+		s.memNext--
+	} else if s.fileNext >= 0 {
+		s.fileNext--
+	}
+}
+
+// readFile loads the log file lines older than the memory buffer
+func (s *logSource) readFile() {
+	s.fileRead = true
+	s.fileNext = -1
+	if s.state.logFile == nil {
+		return
+	}
+
+	before := time.Now()
+	if !s.memOldest.IsZero() {
+		before = s.memOldest
+	}
+	// Nothing in the files is recent enough
+	if !s.since.IsZero() && before.Before(s.since) {
+		return
+	}
+
+	s.state.Mu.Lock()
+	clearedAt := s.state.clearedAt
+	s.state.Mu.Unlock()
+
+	for _, line := range s.state.logFile.tail(queryFileLines, before) {
+		if line.Timestamp.After(clearedAt) {
+			line.Service = s.name
+			s.file = append(s.file, line)
+		}
+	}
+	s.fileNext = len(s.file) - 1
+}
+
+// LinesAfter returns the stored lines numbered after seq that match, in the
+// order they were stored, and the Seq of the last line stored so far. Lines
+// stored later get a higher Seq, so a client that has the lines up to that
+// number can follow on without gaps or duplicates. If more than limit lines
+// match, only the newest are returned. A seq the runner hasn't reached yet
+// comes from an earlier daemon, so all stored lines are returned.
+func (r *Runner) LinesAfter(seq uint64, match func(types.LogLine) bool, limit int) ([]types.LogLine, uint64) {
+	// No line can be stored while the buffers are captured
+	r.logMu.Lock()
+	last := r.logSeq
+	var snapshots []types.LogSnapshot
+	for _, state := range r.States() {
+		snapshots = append(snapshots, state.Logs.Snapshot())
+	}
+	r.logMu.Unlock()
+
+	if seq > last {
+		seq = 0
+	}
+
+	var lines []types.LogLine
+	for _, snap := range snapshots {
+		for i := snap.Len() - 1; i >= 0; i-- {
+			line := snap.At(i)
+			if line.Seq <= seq {
+				break
+			}
+			if match == nil || match(line) {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	slices.SortFunc(lines, func(a, b types.LogLine) int { return cmp.Compare(a.Seq, b.Seq) })
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	return lines, last
+}
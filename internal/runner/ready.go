@@ -0,0 +1,149 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"devir/internal/config"
+	"devir/internal/types"
+)
+
+// watchReadiness probes a long-running service until it becomes ready, then
+// keeps probing to track its health. It exits when the run is replaced or stops.
+func (r *Runner) watchReadiness(name string, state *ServiceState, runID uint64) {
+	check := state.Service.Ready
+	deadline := time.Now().Add(check.Timeout)
+
+	for {
+		err := r.probe(state)
+
+		state.Mu.Lock()
+		if state.runID != runID || !state.Running {
+			state.Mu.Unlock()
+			return
+		}
+		prev := state.Status
+		switch {
+		case err == nil:
+			state.Status = types.StatusHealthy
+		case prev == types.StatusStarting && time.Now().Before(deadline):
+			// Still within the startup window
+		default:
+			state.Status = types.StatusUnhealthy
+		}
+		status := state.Status
+		state.Mu.Unlock()
+
+		if status != prev {
+			switch status {
+			case types.StatusHealthy:
+				text := "Ready"
+				if prev == types.StatusUnhealthy {
+					text = "Healthy again"
+				}
+				r.LogChan <- types.LogLine{
+					Service:   name,
+					Text:      text,
+					Timestamp: time.Now(),
+				}
+			case types.StatusUnhealthy:
+				text := "Health check failed: " + err.Error()
+				if prev == types.StatusStarting {
+					text = fmt.Sprintf("Not ready after %s: %s", check.Timeout, err.Error())
+				}
+				r.LogChan <- types.LogLine{
+					Service:   name,
+					Text:      text,
+					Timestamp: time.Now(),
+					IsError:   true,
+				}
+			}
+		}
+
+		time.Sleep(check.Interval)
+	}
+}
+
+// probe runs the configured readiness check once
+func (r *Runner) probe(state *ServiceState) error {
+	svc := state.Service
+	check := svc.Ready
+	timeout := check.Interval
+	if timeout < time.Second {
+		timeout = time.Second
+	}
+
+	switch {
+	case check.HTTP != "":
+		url := check.HTTP
+		if strings.HasPrefix(url, "/") {
+			url = fmt.Sprintf("http://localhost:%d%s", svc.Port, url)
+		}
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != check.Status {
+			return fmt.Errorf("GET %s returned %d, expected %d", url, resp.StatusCode, check.Status)
+		}
+		return nil
+
+	case check.TCP:
+		conn, err := net.DialTimeout("tcp", fmt.Sprintf("localhost:%d", svc.Port), timeout)
+		if err != nil {
+			return err
+		}
+		_ = conn.Close()
+		return nil
+
+	case check.Log != "":
+		state.Mu.Lock()
+		matched := state.readyMatched
+		state.Mu.Unlock()
+		if !matched {
+			return fmt.Errorf("no output matching %q", check.Log)
+		}
+		return nil
+
+	case check.Cmd != "":
+		env, err := r.currentConfig().ResolveEnv(svc)
+		if err != nil {
+			return err
+		}
+		cmd := ShellCommand(check.Cmd)
+		cmd.Dir = filepath.Join(r.currentConfig().RootDir, svc.Dir)
+		cmd.Env = config.EnvList(env)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s: %w", check.Cmd, err)
+		}
+		return nil
+	}
+
+	return nil
+}
+
+// matchReadyLog marks a log-pattern probe as passed when text matches
+func (s *ServiceState) matchReadyLog(text string) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	if s.readyPattern != nil && !s.readyMatched && s.readyPattern.MatchString(text) {
+		s.readyMatched = true
+	}
+}
+
+// resetReadiness prepares readiness state for a new run (caller holds Mu)
+func (s *ServiceState) resetReadiness() {
+	s.readyMatched = false
+	s.readyPattern = nil
+	if rc := s.Service.Ready; rc != nil && rc.Log != "" {
+		s.readyPattern, _ = regexp.Compile(rc.Log)
+	}
+}
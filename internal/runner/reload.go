@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"maps"
+	"slices"
+
+	"devir/internal/config"
+)
+
+// currentConfig returns the config, which Reload may replace
+func (r *Runner) currentConfig() *config.Config {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Config
+}
+
+// currentRedactor returns the redactor for the current config
+func (r *Runner) currentRedactor() *redactor {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.redactor
+}
+
+// Reload switches the runner to a new config. Removed services are stopped.
+// Changed services are stopped and, unless they were stopped already, started
+// again with their new definition. Added services are started if they are
+// among the new defaults. Services that didn't change keep running. Returns
+// the services started.
+func (r *Runner) Reload(cfg *config.Config, diff config.Diff) []string {
+	var stop []*ServiceState
+	var start []string
+
+	r.mu.Lock()
+	services := maps.Clone(r.Services)
+	for _, name := range diff.Removed {
+		if state := services[name]; state != nil {
+			stop = append(stop, state)
+			delete(services, name)
+		}
+	}
+
+	r.Config = cfg
+	r.redactor = newRedactor(cfg)
+
+	for _, name := range diff.Changed {
+		state := services[name]
+		if state == nil {
+			continue
+		}
+		stop = append(stop, state)
+		if !state.Idle() {
+			start = append(start, name)
+		}
+		services[name] = r.replaceServiceState(state, cfg.Services[name])
+	}
+
+	// Dependencies may have changed too
+	order := cfg.ResolveDependencies(r.ServiceOrder)
+	r.ServiceOrder = slices.DeleteFunc(order, func(name string) bool { return services[name] == nil })
+	r.Services = services
+	r.mu.Unlock()
+
+	// Old processes go first, so restarted services get their ports back
+	for i := len(stop) - 1; i >= 0; i-- {
+		state := stop[i]
+		state.stopWatcher()
+		r.stopService(state)
+		if state.logFile != nil {
+			state.logFile.close()
+		}
+	}
+
+	for _, name := range diff.Added {
+		if slices.Contains(cfg.Defaults, name) {
+			start = append(start, name)
+		}
+	}
+	if len(start) == 0 {
+		return nil
+	}
+	return r.AddServices(start)
+}
+
+// replaceServiceState creates the state for a changed service definition. The
+// logs are kept, unless the buffer size changed. Callers hold r.mu.
+func (r *Runner) replaceServiceState(old *ServiceState, svc config.Service) *ServiceState {
+	state := r.newServiceState(old.Name, svc)
+	if svc.LogBuffer == old.Service.LogBuffer {
+		state.Logs = old.Logs
+	} else {
+		for _, line := range old.Logs.Snapshot().Lines() {
+			state.Logs.Append(line)
+		}
+	}
+
+	old.Mu.Lock()
+	state.clearedAt = old.clearedAt
+	old.Mu.Unlock()
+	return state
+}
@@ -0,0 +1,117 @@
+package runner
+
+import (
+	"fmt"
+	"time"
+
+	"devir/internal/config"
+	"devir/internal/types"
+)
+
+// crashLoopThreshold is the number of consecutive restarts after which a
+// service is reported as crash looping
+const crashLoopThreshold = 3
+
+// scheduleRestart applies the service's restart policy after an unexpected
+// exit. It returns false if the policy does not apply, leaving the caller to
+// report the exit. runID is the run that just exited; if a manual start, stop
+// or restart replaces it during the backoff, the automatic restart is dropped.
+func (r *Runner) scheduleRestart(name string, state *ServiceState, runID uint64, failed bool, exitCode int) bool {
+	svc := state.Service
+	switch svc.Restart {
+	case config.RestartAlways:
+	case config.RestartOnFailure:
+		if !failed {
+			return false
+		}
+	default:
+		return false
+	}
+
+	state.Mu.Lock()
+	if state.runID != runID || state.stopping {
+		state.Mu.Unlock()
+		return false
+	}
+
+	if svc.MaxRestarts > 0 && state.Restarts >= svc.MaxRestarts {
+		state.Status = types.StatusFailed
+		state.CrashLoop = true
+		restarts := state.Restarts
+		state.Mu.Unlock()
+
+		r.LogChan <- types.LogLine{
+			Service:   name,
+			Text:      fmt.Sprintf("Exited (exit %d), giving up after %d restarts", exitCode, restarts),
+			Timestamp: time.Now(),
+			IsError:   true,
+		}
+		r.stopDependents(name)
+		return true
+	}
+
+	delay := backoffDelay(svc, state.Restarts)
+	state.Restarts++
+	attempt := state.Restarts
+	state.Status = types.StatusRestarting
+	if state.Restarts >= crashLoopThreshold {
+		state.CrashLoop = true
+		state.Status = types.StatusCrashLoop
+	}
+	state.Mu.Unlock()
+
+	r.LogChan <- types.LogLine{
+		Service:   name,
+		Text:      fmt.Sprintf("Exited (exit %d), restarting in %s (attempt %d)", exitCode, delay, attempt),
+		Timestamp: time.Now(),
+		IsError:   failed,
+	}
+
+	go func() {
+		time.Sleep(delay)
+
+		state.Mu.Lock()
+		current := state.runID == runID && !state.stopping
+		state.Mu.Unlock()
+
+		if current {
+			r.startService(name)
+		}
+	}()
+
+	return true
+}
+
+// backoffDelay returns the delay before the given restart attempt (0-based):
+// BackoffBase doubled per attempt, capped at BackoffMax
+func backoffDelay(svc config.Service, attempt int) time.Duration {
+	delay := svc.BackoffBase
+	for i := 0; i < attempt && delay < svc.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > svc.BackoffMax {
+		delay = svc.BackoffMax
+	}
+	return delay
+}
+
+// resetRestartsWhenStable clears the restart streak once a run has stayed up
+// for BackoffMax
+func (r *Runner) resetRestartsWhenStable(state *ServiceState, runID uint64) {
+	time.AfterFunc(state.Service.BackoffMax, func() {
+		state.Mu.Lock()
+		defer state.Mu.Unlock()
+		if state.runID == runID && state.Running {
+			state.Restarts = 0
+			state.CrashLoop = false
+		}
+	})
+}
+
+// resetRestarts clears the automatic restart streak after a manual action
+func (s *ServiceState) resetRestarts() {
+	s.Mu.Lock()
+	s.Restarts = 0
+	s.CrashLoop = false
+	s.Mu.Unlock()
+}
@@ -1,16 +1,17 @@
 package runner
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
@@ -25,17 +26,31 @@ type ServiceState struct {
 	Service     config.Service
 	Cmd         *exec.Cmd
 	Running     bool
-	Logs        []types.LogLine
+	Logs        *types.LogBuffer
 	Mu          sync.Mutex // Exported for daemon access
 	Status      types.ServiceStatus
 	LastRun     time.Time
 	NextRun     time.Time
 	ExitCode    int
 	RunCount    int
-	ticker      *time.Ticker
 	stopChan    chan struct{}
 	DynamicIcon string // Icon from .devir-status file
 	runID       uint64 // Generation counter for race condition prevention
+	Restarts    int    // Consecutive automatic restarts
+	CrashLoop   bool   // Restarting repeatedly without staying up
+	stopping    bool   // Set when a stop was requested so the exit isn't treated as a failure
+
+	readyPattern *regexp.Regexp // Log pattern for the readiness probe
+	readyMatched bool           // Log pattern seen during the current run
+
+	watchStop chan struct{} // Closed to stop the file watcher
+
+	StopResult types.StopResult // How the last requested stop ended
+	done       chan struct{}    // Closed when the current process has exited
+	stdin      io.Writer        // Input of the current process, nil if it takes none
+
+	logFile   *logFile  // Persistent log file, nil if disabled
+	clearedAt time.Time // Log file lines before this are hidden after ClearLogs
 }
 
 // Runner manages multiple services
@@ -47,19 +62,27 @@ type Runner struct {
 	LogEntryChan  chan types.LogEntry // For TUI mode
 	filter        *regexp.Regexp
 	exclude       *regexp.Regexp
+	redactor      *redactor
 	activeService string // Empty = all, or specific service name
 	tuiMode       bool
 	mu            sync.RWMutex
+
+	logMu  sync.Mutex // Held while a line is numbered, stored and forwarded
+	logSeq uint64     // Seq of the last stored line
 }
 
-// New creates a new Runner
+// New creates a new Runner. Dependencies of the requested services are
+// added automatically and ServiceOrder is sorted topologically.
 func New(cfg *config.Config, serviceNames []string, filterPattern, excludePattern string) *Runner {
+	serviceNames = cfg.ResolveDependencies(serviceNames)
+
 	r := &Runner{
 		Config:       cfg,
 		Services:     make(map[string]*ServiceState),
 		ServiceOrder: serviceNames,
 		LogChan:      make(chan types.LogLine, 1000),
 		LogEntryChan: make(chan types.LogEntry, 1000),
+		redactor:     newRedactor(cfg),
 	}
 
 	// Compile filter patterns
@@ -73,19 +96,28 @@ func New(cfg *config.Config, serviceNames []string, filterPattern, excludePatter
 	// Initialize service states
 	for _, name := range serviceNames {
 		if svc, ok := cfg.Services[name]; ok {
-			r.Services[name] = &ServiceState{
-				Name:     name,
-				Service:  svc,
-				Logs:     make([]types.LogLine, 0, 1000),
-				Status:   types.StatusStopped,
-				stopChan: make(chan struct{}),
-			}
+			r.Services[name] = r.newServiceState(name, svc)
 		}
 	}
 
 	return r
 }
 
+// newServiceState creates the state of a service that hasn't run yet. Callers
+// construct the runner or hold r.mu.
+func (r *Runner) newServiceState(name string, svc config.Service) *ServiceState {
+	state := &ServiceState{
+		Name:    name,
+		Service: svc,
+		Logs:    types.NewLogBuffer(svc.LogBuffer),
+		Status:  types.StatusStopped,
+	}
+	if svc.Logs != nil {
+		state.logFile = newLogFile(r.Config.RootDir, name, svc.Logs)
+	}
+	return state
+}
+
 // SetActiveService sets which service logs to show (empty = all)
 func (r *Runner) SetActiveService(name string) {
 	r.mu.Lock()
@@ -127,16 +159,23 @@ func (r *Runner) CycleService() string {
 // Start starts all services in simple mode
 func (r *Runner) Start() {
 	go r.printLogs()
-	for name := range r.Services {
-		go r.startService(name)
-	}
+	r.startAll()
 }
 
 // StartWithChannel starts services in TUI mode
 func (r *Runner) StartWithChannel() {
 	r.tuiMode = true
-	for name := range r.Services {
-		go r.startService(name)
+	go r.forwardLogChan()
+	r.startAll()
+}
+
+// startAll launches every service in dependency order. Each service waits
+// for its own dependencies, so independent services still start in parallel.
+func (r *Runner) startAll() {
+	for _, name := range r.ServiceOrder {
+		if _, ok := r.Services[name]; ok {
+			go r.startService(name)
+		}
 	}
 }
 
@@ -164,12 +203,25 @@ func (r *Runner) KillPort(port int) error {
 	return nil
 }
 
-// Stop stops all services
+// Stop stops all services, dependents before their dependencies
 func (r *Runner) Stop() {
+	// Output of the stopping services is processed under r.mu, so it must
+	// not be held while waiting for them to exit
+	var states []*ServiceState
 	r.mu.RLock()
-	defer r.mu.RUnlock()
-	for _, state := range r.Services {
+	for i := len(r.ServiceOrder) - 1; i >= 0; i-- {
+		if state, ok := r.Services[r.ServiceOrder[i]]; ok {
+			states = append(states, state)
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, state := range states {
+		state.stopWatcher()
 		r.stopService(state)
+		if state.logFile != nil {
+			state.logFile.close()
+		}
 	}
 }
 
@@ -182,6 +234,12 @@ func (r *Runner) startService(name string) {
 		return
 	}
 
+	r.ensureWatcher(name, state)
+
+	if !r.waitForDependencies(name, state) {
+		return
+	}
+
 	// Dispatch based on service type
 	switch state.Service.Type {
 	case config.ServiceTypeHTTP:
@@ -195,39 +253,206 @@ func (r *Runner) startService(name string) {
 	}
 }
 
+// waitForDependencies blocks until every dependency of a service is ready.
+// Returns false if a dependency failed or the service was stopped while waiting.
+func (r *Runner) waitForDependencies(name string, state *ServiceState) bool {
+	deps := state.Service.DependsOn
+	if len(deps) == 0 {
+		return true
+	}
+
+	state.Mu.Lock()
+	state.runID++
+	currentRunID := state.runID
+	state.stopping = false
+	state.Status = types.StatusWaiting
+	state.Mu.Unlock()
+
+	lastPending := ""
+	for {
+		pending := ""
+		for _, dep := range deps {
+			r.mu.RLock()
+			depState := r.Services[dep]
+			r.mu.RUnlock()
+
+			if depState == nil {
+				continue
+			}
+
+			ready, failed := depState.dependencyReady()
+			if failed {
+				state.Mu.Lock()
+				if state.runID == currentRunID {
+					state.Status = types.StatusFailed
+				}
+				state.Mu.Unlock()
+				r.LogChan <- types.LogLine{
+					Service:   name,
+					Text:      fmt.Sprintf("Not started: dependency %s failed", dep),
+					Timestamp: time.Now(),
+					IsError:   true,
+				}
+				return false
+			}
+			if !ready && pending == "" {
+				pending = dep
+			}
+		}
+
+		if pending == "" {
+			return true
+		}
+
+		if pending != lastPending {
+			r.LogChan <- types.LogLine{
+				Service:   name,
+				Text:      "Waiting for " + pending,
+				Timestamp: time.Now(),
+			}
+			lastPending = pending
+		}
+
+		time.Sleep(100 * time.Millisecond)
+
+		state.Mu.Lock()
+		cancelled := state.runID != currentRunID || state.stopping
+		if cancelled && state.runID == currentRunID {
+			state.Status = types.StatusStopped
+		}
+		state.Mu.Unlock()
+		if cancelled {
+			return false
+		}
+	}
+}
+
+// dependencyReady reports whether dependents of this service may start.
+// Long-running services are ready once running (or healthy, with a readiness
+// probe), interval services once running, oneshot and http services once
+// completed successfully.
+func (s *ServiceState) dependencyReady() (ready, failed bool) {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+
+	if s.Status == types.StatusFailed && !s.Running {
+		return false, true
+	}
+
+	switch s.Service.Type {
+	case config.ServiceTypeOneshot, config.ServiceTypeHTTP:
+		return s.Status == types.StatusCompleted, false
+	case config.ServiceTypeInterval:
+		return s.Running, false
+	default:
+		return s.Running && (s.Status == types.StatusRunning || s.Status == types.StatusHealthy), false
+	}
+}
+
+// stopDependents stops running services that (transitively) depend on name
+func (r *Runner) stopDependents(name string) {
+	r.mu.RLock()
+	var dependents []*ServiceState
+	for _, state := range r.Services {
+		for _, dep := range state.Service.DependsOn {
+			if dep == name {
+				dependents = append(dependents, state)
+				break
+			}
+		}
+	}
+	r.mu.RUnlock()
+
+	for _, state := range dependents {
+		state.Mu.Lock()
+		running := state.Running
+		state.Mu.Unlock()
+
+		if running {
+			r.LogChan <- types.LogLine{
+				Service:   state.Name,
+				Text:      fmt.Sprintf("Stopping: dependency %s failed", name),
+				Timestamp: time.Now(),
+				IsError:   true,
+			}
+			r.stopService(state)
+		}
+		r.stopDependents(state.Name)
+	}
+}
+
+// newCommand builds the command for a service with its working directory
+// and resolved environment. args is used as exact argv; otherwise cmd runs
+// through the shell, or is split on whitespace when shell is off.
+func (r *Runner) newCommand(svc config.Service) (*exec.Cmd, error) {
+	env, err := r.currentConfig().ResolveEnv(svc)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	switch {
+	case len(svc.Args) > 0:
+		cmd = exec.Command(svc.Args[0], svc.Args[1:]...)
+	case svc.UsesShell():
+		cmd = ShellCommand(svc.Cmd)
+	default:
+		parts := strings.Fields(svc.Cmd)
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("empty command")
+		}
+		cmd = exec.Command(parts[0], parts[1:]...)
+	}
+	cmd.Dir = filepath.Join(r.currentConfig().RootDir, svc.Dir)
+	cmd.Env = config.EnvList(env)
+	return cmd, nil
+}
+
 // startLongRunningService starts a continuously running service
 func (r *Runner) startLongRunningService(name string, state *ServiceState) {
 	svc := state.Service
-	workDir := filepath.Join(r.Config.RootDir, svc.Dir)
 
-	parts := strings.Fields(svc.Cmd)
-	if len(parts) == 0 {
+	// A later start may take over while the command is prepared
+	state.Mu.Lock()
+	prevRunID := state.runID
+	state.Mu.Unlock()
+
+	cmd, err := r.newCommand(svc)
+	if err != nil {
+		state.Mu.Lock()
+		if state.runID == prevRunID {
+			state.Running = false
+			state.Status = types.StatusFailed
+		}
+		state.Mu.Unlock()
+		r.LogChan <- types.LogLine{
+			Service:   name,
+			Text:      "Failed to start: " + err.Error(),
+			Timestamp: time.Now(),
+			IsError:   true,
+		}
+		r.stopDependents(name)
 		return
 	}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Dir = workDir
-	cmd.Env = append(os.Environ(),
-		"CI=true",
-		"TERM=dumb",
-		"NO_COLOR=1",
-		"FORCE_COLOR=0",
-	)
-
-	SetSysProcAttr(cmd)
-
-	stdout, _ := cmd.StdoutPipe()
-	stderr, _ := cmd.StderrPipe()
-
 	state.Mu.Lock()
 	state.runID++
 	currentRunID := state.runID
+	state.stopping = false
 	state.Cmd = cmd
+	done := make(chan struct{})
+	state.done = done
 	state.Running = true
 	state.Status = types.StatusRunning
+	if svc.Ready != nil {
+		state.Status = types.StatusStarting
+		state.resetReadiness()
+	}
 	state.Mu.Unlock()
 
-	if err := cmd.Start(); err != nil {
+	out, err := startProcess(cmd, svc)
+	if err != nil {
+		close(done)
 		state.Mu.Lock()
 		if state.runID == currentRunID {
 			state.Running = false
@@ -240,61 +465,90 @@ func (r *Runner) startLongRunningService(name string, state *ServiceState) {
 			Timestamp: time.Now(),
 			IsError:   true,
 		}
+		r.stopDependents(name)
 		return
 	}
 
+	state.Mu.Lock()
+	state.stdin = out.stdin
+	state.Mu.Unlock()
+
 	r.LogChan <- types.LogLine{
 		Service:   name,
 		Text:      "Started (port " + formatPort(svc.Port) + ")",
 		Timestamp: time.Now(),
 	}
 
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
-		for scanner.Scan() {
-			r.processLine(name, scanner.Text(), false)
-		}
-	}()
+	if svc.Ready != nil {
+		go r.watchReadiness(name, state, currentRunID)
+	}
+	if svc.RestartEnabled() {
+		r.resetRestartsWhenStable(state, currentRunID)
+	}
 
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
-		for scanner.Scan() {
-			r.processLine(name, scanner.Text(), true)
-		}
-	}()
+	drained := r.readOutput(name, state.Service, out)
 
-	_ = cmd.Wait()
+	err = cmd.Wait()
+	waitOutput(drained)
 
 	// Only update state if this run is still current (prevents race condition)
+	unexpected := false
+	failed := false
+	exitCode := 0
 	state.Mu.Lock()
 	if state.runID == currentRunID {
 		state.Running = false
 		state.Status = types.StatusStopped
+		state.ExitCode = 0
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			state.ExitCode = exitErr.ExitCode()
+		} else if err != nil {
+			state.ExitCode = -1
+		}
+		exitCode = state.ExitCode
+		// An exit nobody asked for is a crash
+		unexpected = !state.stopping
+		if err != nil && unexpected {
+			state.Status = types.StatusFailed
+			failed = true
+		}
 	}
 	state.Mu.Unlock()
+	close(done)
+
+	// Requested stops are reported by stopService
+	if !unexpected {
+		return
+	}
+
+	if r.scheduleRestart(name, state, currentRunID, failed, exitCode) {
+		return
+	}
+
+	if failed {
+		r.LogChan <- types.LogLine{
+			Service:   name,
+			Text:      fmt.Sprintf("Exited unexpectedly (exit %d)", exitCode),
+			Timestamp: time.Now(),
+			IsError:   true,
+		}
+		r.stopDependents(name)
+		return
+	}
 
 	r.LogChan <- types.LogLine{
 		Service:   name,
-		Text:      "Stopped",
+		Text:      "Exited (exit 0)",
 		Timestamp: time.Now(),
 	}
 }
 
 // startOneshotService runs a command once and exits
 func (r *Runner) startOneshotService(name string, state *ServiceState) {
-	svc := state.Service
-	workDir := filepath.Join(r.Config.RootDir, svc.Dir)
-
-	parts := strings.Fields(svc.Cmd)
-	if len(parts) == 0 {
-		return
-	}
-
 	state.Mu.Lock()
 	state.runID++
 	currentRunID := state.runID
+	state.stopping = false
 	state.Running = true
 	state.Status = types.StatusRunning
 	state.LastRun = time.Now()
@@ -307,25 +561,25 @@ func (r *Runner) startOneshotService(name string, state *ServiceState) {
 		Timestamp: time.Now(),
 	}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Dir = workDir
-	cmd.Env = append(os.Environ(),
-		"CI=true",
-		"TERM=dumb",
-		"NO_COLOR=1",
-		"FORCE_COLOR=0",
-	)
-
-	SetSysProcAttr(cmd)
-
-	stdout, _ := cmd.StdoutPipe()
-	stderr, _ := cmd.StderrPipe()
+	cmd, err := r.newCommand(state.Service)
+	var out *processOutput
+	done := make(chan struct{})
+	if err == nil {
+		state.Mu.Lock()
+		state.Cmd = cmd
+		state.done = done
+		state.Mu.Unlock()
 
-	state.Mu.Lock()
-	state.Cmd = cmd
-	state.Mu.Unlock()
+		out, err = startProcess(cmd, state.Service)
+		if err == nil {
+			state.Mu.Lock()
+			state.stdin = out.stdin
+			state.Mu.Unlock()
+		}
+	}
 
-	if err := cmd.Start(); err != nil {
+	if err != nil {
+		close(done)
 		state.Mu.Lock()
 		if state.runID == currentRunID {
 			state.Running = false
@@ -339,26 +593,14 @@ func (r *Runner) startOneshotService(name string, state *ServiceState) {
 			Timestamp: time.Now(),
 			IsError:   true,
 		}
+		r.stopDependents(name)
 		return
 	}
 
-	go func() {
-		scanner := bufio.NewScanner(stdout)
-		scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
-		for scanner.Scan() {
-			r.processLine(name, scanner.Text(), false)
-		}
-	}()
+	drained := r.readOutput(name, state.Service, out)
 
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		scanner.Buffer(make([]byte, 0, 1024*1024), 1024*1024)
-		for scanner.Scan() {
-			r.processLine(name, scanner.Text(), true)
-		}
-	}()
-
-	err := cmd.Wait()
+	err = cmd.Wait()
+	waitOutput(drained)
 
 	// Only update state if this run is still current (prevents race condition)
 	state.Mu.Lock()
@@ -377,6 +619,7 @@ func (r *Runner) startOneshotService(name string, state *ServiceState) {
 		}
 	}
 	state.Mu.Unlock()
+	close(done)
 
 	if err != nil {
 		r.LogChan <- types.LogLine{
@@ -385,6 +628,7 @@ func (r *Runner) startOneshotService(name string, state *ServiceState) {
 			Timestamp: time.Now(),
 			IsError:   true,
 		}
+		r.stopDependents(name)
 	} else {
 		r.LogChan <- types.LogLine{
 			Service:   name,
@@ -401,12 +645,18 @@ func (r *Runner) startIntervalService(name string, state *ServiceState) {
 	state.Mu.Lock()
 	state.runID++
 	currentRunID := state.runID
+	state.stopping = false
 	state.Running = true
 	state.Status = types.StatusWaiting
-	state.ticker = time.NewTicker(svc.Interval)
 	state.NextRun = time.Now()
+	// A stop while a run is in progress is seen once it finishes
+	stop := make(chan struct{})
+	state.stopChan = stop
 	state.Mu.Unlock()
 
+	ticker := time.NewTicker(svc.Interval)
+	defer ticker.Stop()
+
 	r.LogChan <- types.LogLine{
 		Service:   name,
 		Text:      fmt.Sprintf("[interval] Started (every %s)", svc.Interval),
@@ -418,18 +668,15 @@ func (r *Runner) startIntervalService(name string, state *ServiceState) {
 
 	for {
 		select {
-		case <-state.ticker.C:
+		case <-ticker.C:
 			r.runIntervalCommand(name, state)
-		case <-state.stopChan:
+		case <-stop:
 			// Only update state if this run is still current (prevents race condition)
 			state.Mu.Lock()
 			if state.runID == currentRunID {
 				state.Running = false
 				state.Status = types.StatusStopped
 			}
-			if state.ticker != nil {
-				state.ticker.Stop()
-			}
 			state.Mu.Unlock()
 			r.LogChan <- types.LogLine{
 				Service:   name,
@@ -443,12 +690,6 @@ func (r *Runner) startIntervalService(name string, state *ServiceState) {
 
 func (r *Runner) runIntervalCommand(name string, state *ServiceState) {
 	svc := state.Service
-	workDir := filepath.Join(r.Config.RootDir, svc.Dir)
-
-	parts := strings.Fields(svc.Cmd)
-	if len(parts) == 0 {
-		return
-	}
 
 	state.Mu.Lock()
 	state.LastRun = time.Now()
@@ -463,23 +704,19 @@ func (r *Runner) runIntervalCommand(name string, state *ServiceState) {
 		Timestamp: time.Now(),
 	}
 
-	cmd := exec.Command(parts[0], parts[1:]...)
-	cmd.Dir = workDir
-	cmd.Env = append(os.Environ(),
-		"CI=true",
-		"TERM=dumb",
-		"NO_COLOR=1",
-		"FORCE_COLOR=0",
-	)
-
-	output, err := cmd.CombinedOutput()
+	var output []byte
+	cmd, err := r.newCommand(svc)
+	if err == nil {
+		output, err = cmd.CombinedOutput()
+	} else {
+		output = []byte("Failed to start: " + err.Error())
+	}
 	if len(output) > 0 {
-		lines := strings.Split(strings.TrimSpace(string(output)), "\n")
-		for _, line := range lines {
-			if line != "" {
-				r.processLine(name, line, err != nil)
-			}
+		g := r.newLineGrouper(name, svc, err != nil)
+		for _, line := range strings.Split(strings.TrimSpace(string(output)), "\n") {
+			g.add(line)
 		}
+		g.flush()
 	}
 
 	state.Mu.Lock()
@@ -502,6 +739,7 @@ func (r *Runner) startHTTPService(name string, state *ServiceState) {
 	state.Mu.Lock()
 	state.runID++
 	currentRunID := state.runID
+	state.stopping = false
 	state.Running = true
 	state.Status = types.StatusRunning
 	state.LastRun = time.Now()
@@ -513,13 +751,24 @@ func (r *Runner) startHTTPService(name string, state *ServiceState) {
 		Text:      fmt.Sprintf("[http] %s %s", svc.Method, svc.URL),
 		Timestamp: time.Now(),
 	}
-
-	var bodyReader io.Reader
-	if svc.Body != "" {
-		bodyReader = bytes.NewBufferString(svc.Body)
+	if len(svc.Headers) > 0 {
+		r.LogChan <- types.LogLine{
+			Service:   name,
+			Text:      "[http] Headers: " + r.currentRedactor().describeHeaders(svc.Headers),
+			Timestamp: time.Now(),
+		}
 	}
 
-	req, err := http.NewRequest(svc.Method, svc.URL, bodyReader)
+	// URL, body and headers may reference the service environment
+	var req *http.Request
+	env, err := r.currentConfig().ResolveEnv(svc)
+	if err == nil {
+		var bodyReader io.Reader
+		if svc.Body != "" {
+			bodyReader = bytes.NewBufferString(config.ExpandDefined(svc.Body, env))
+		}
+		req, err = http.NewRequest(svc.Method, config.ExpandDefined(svc.URL, env), bodyReader)
+	}
 	if err != nil {
 		state.Mu.Lock()
 		if state.runID == currentRunID {
@@ -533,6 +782,7 @@ func (r *Runner) startHTTPService(name string, state *ServiceState) {
 			Timestamp: time.Now(),
 			IsError:   true,
 		}
+		r.stopDependents(name)
 		return
 	}
 
@@ -545,7 +795,7 @@ func (r *Runner) startHTTPService(name string, state *ServiceState) {
 	for _, h := range svc.Headers {
 		parts := strings.SplitN(h, ":", 2)
 		if len(parts) == 2 {
-			req.Header.Set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1]))
+			req.Header.Set(strings.TrimSpace(parts[0]), config.ExpandDefined(strings.TrimSpace(parts[1]), env))
 		}
 	}
 
@@ -564,6 +814,7 @@ func (r *Runner) startHTTPService(name string, state *ServiceState) {
 			Timestamp: time.Now(),
 			IsError:   true,
 		}
+		r.stopDependents(name)
 		return
 	}
 	defer func() { _ = resp.Body.Close() }()
@@ -603,26 +854,82 @@ func (r *Runner) startHTTPService(name string, state *ServiceState) {
 			IsError:   isError,
 		}
 	}
+
+	if isError {
+		r.stopDependents(name)
+	}
 }
 
 func (r *Runner) stopService(state *ServiceState) {
+	state.Mu.Lock()
+	state.stopping = true
+	// A pending automatic restart is cancelled by the stop
+	if state.Status == types.StatusRestarting || state.Status == types.StatusCrashLoop {
+		state.Status = types.StatusStopped
+	}
+	state.Mu.Unlock()
+
 	// Handle interval services with stopChan
 	if state.Service.Type == config.ServiceTypeInterval {
-		select {
-		case state.stopChan <- struct{}{}:
-		default:
+		state.Mu.Lock()
+		if state.stopChan != nil {
+			close(state.stopChan)
+			state.stopChan = nil
 		}
+		state.Mu.Unlock()
 		return
 	}
 
 	state.Mu.Lock()
-	defer state.Mu.Unlock()
+	cmd := state.Cmd
+	done := state.done
+	state.Mu.Unlock()
 
-	if state.Cmd != nil && state.Cmd.Process != nil {
-		KillProcessGroup(state.Cmd.Process.Pid)
-		time.Sleep(100 * time.Millisecond)
-		ForceKillProcessGroup(state.Cmd.Process.Pid)
+	if cmd == nil || cmd.Process == nil || done == nil {
+		return
+	}
+
+	// Nothing to do if the process already exited
+	select {
+	case <-done:
+		return
+	default:
+	}
+
+	svc := state.Service
+	pid := cmd.Process.Pid
+	started := time.Now()
+	SignalProcessGroup(pid, svc.StopSignal)
+
+	result := types.StopGraceful
+	select {
+	case <-done:
+	case <-time.After(svc.StopTimeout):
+		result = types.StopKilled
+		ForceKillProcessGroup(pid)
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+		}
 	}
+
+	// Clean up children left behind in the process group
+	ForceKillProcessGroup(pid)
+
+	state.Mu.Lock()
+	state.StopResult = result
+	state.Mu.Unlock()
+
+	line := types.LogLine{
+		Service:   state.Name,
+		Text:      fmt.Sprintf("Stopped (%s, %s)", svc.StopSignal, time.Since(started).Round(time.Millisecond)),
+		Timestamp: time.Now(),
+	}
+	if result == types.StopKilled {
+		line.Text = fmt.Sprintf("Killed: no exit within %s after %s", svc.StopTimeout, svc.StopSignal)
+		line.IsError = true
+	}
+	r.LogChan <- line
 }
 
 // RestartService restarts a specific service
@@ -636,6 +943,7 @@ func (r *Runner) RestartService(name string) {
 	}
 
 	r.stopService(state)
+	state.resetRestarts()
 	time.Sleep(500 * time.Millisecond)
 	go r.startService(name)
 }
@@ -650,11 +958,9 @@ func (r *Runner) StopService(name string) {
 		return
 	}
 
+	state.stopWatcher()
 	r.stopService(state)
 
-	// Wait for process to fully terminate
-	time.Sleep(200 * time.Millisecond)
-
 	state.Mu.Lock()
 	state.Running = false
 	state.Status = types.StatusStopped
@@ -674,9 +980,105 @@ func (r *Runner) StartService(name string) {
 
 	// Force start - don't check if already running
 	// The user explicitly wants to start this service
+	state.resetRestarts()
 	go r.startService(name)
 }
 
+// AddServices starts services, with their dependencies, that the runner
+// doesn't have yet or that are stopped or failed. Running services are left
+// alone. Returns the names of the services started, in start order.
+func (r *Runner) AddServices(names []string) []string {
+	names = r.currentConfig().ResolveDependencies(names)
+
+	// Readers holding the old map or order keep a consistent view
+	r.mu.Lock()
+	services := maps.Clone(r.Services)
+	for _, name := range names {
+		if _, ok := services[name]; ok {
+			continue
+		}
+		if svc, ok := r.Config.Services[name]; ok {
+			services[name] = r.newServiceState(name, svc)
+		}
+	}
+	r.Services = services
+	r.ServiceOrder = r.Config.ResolveDependencies(append(slices.Clone(r.ServiceOrder), names...))
+	r.mu.Unlock()
+
+	var started []string
+	for _, name := range names {
+		state := services[name]
+		if state == nil || !state.Idle() {
+			continue
+		}
+		state.resetRestarts()
+		go r.startService(name)
+		started = append(started, name)
+	}
+	return started
+}
+
+// Idle reports whether a service is stopped or failed, as opposed to
+// running, completed or waiting for its next run or restart
+func (s *ServiceState) Idle() bool {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	return !s.Running && (s.Status == types.StatusStopped || s.Status == types.StatusFailed)
+}
+
+// States returns the service states by name. AddServices replaces the map
+// instead of modifying it, so callers may range over it without locking.
+func (r *Runner) States() map[string]*ServiceState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Services
+}
+
+// State returns the state of a service, nil if the runner doesn't have it
+func (r *Runner) State(name string) *ServiceState {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.Services[name]
+}
+
+// Order returns the runner's services in start order
+func (r *Runner) Order() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ServiceOrder
+}
+
+// WriteStdin sends input to a running service. Services without a
+// terminal get line endings as \n, since nothing translates a typed Enter.
+func (r *Runner) WriteStdin(name string, data []byte) error {
+	r.mu.RLock()
+	state := r.Services[name]
+	r.mu.RUnlock()
+	if state == nil {
+		return fmt.Errorf("unknown service: %s", name)
+	}
+
+	state.Mu.Lock()
+	stdin := state.stdin
+	running := state.Running
+	tty := state.Service.TTY
+	state.Mu.Unlock()
+
+	if !running {
+		return fmt.Errorf("service %s is not running", name)
+	}
+	if stdin == nil {
+		return fmt.Errorf("service %s does not accept input", name)
+	}
+
+	if !tty {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+		data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	}
+	_, err := stdin.Write(data)
+	return err
+}
+
 // ClearLogs clears logs for a specific service or all services
 func (r *Runner) ClearLogs(service string) {
 	r.mu.RLock()
@@ -686,22 +1088,73 @@ func (r *Runner) ClearLogs(service string) {
 		if service != "" && name != service {
 			continue
 		}
+		state.Logs.Clear()
 		state.Mu.Lock()
-		state.Logs = nil
+		state.clearedAt = time.Now()
 		state.Mu.Unlock()
 	}
 }
 
-var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*[a-zA-Z]`)
+// ansiPattern matches terminal escape sequences: CSI sequences (colors,
+// cursor movement, screen clearing) and OSC sequences (titles, hyperlinks)
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[a-zA-Z]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// nonColorPattern matches the escape sequences other than colors (SGR),
+// which are dropped from the styled text of tty services
+var nonColorPattern = regexp.MustCompile(`\x1b\[[0-9;?]*[a-ln-zA-Z]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)`)
+
+// cleanLine drops carriage returns. Progress output that redraws a line
+// with \r keeps only its final state.
+func cleanLine(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if j := strings.LastIndexByte(line, '\r'); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// processLine cleans up, classifies, stores and forwards a line of output.
+// level forces the level of lifecycle messages; output lines pass "".
+func (r *Runner) processLine(service, text string, isError bool, level string) {
+	text = cleanLine(text)
+
+	// Colored text is kept separately for display; everything else uses
+	// the plain text
+	styled := ""
+	if strings.Contains(text, "\x1b[") {
+		styled = strings.TrimSpace(nonColorPattern.ReplaceAllString(text, ""))
+	}
 
-func (r *Runner) processLine(service, text string, isError bool) {
 	text = ansiPattern.ReplaceAllString(text, "")
-	text = strings.ReplaceAll(text, "\r", "")
 	text = strings.TrimSpace(text)
 
 	if text == "" {
 		return
 	}
+	if styled == text {
+		styled = ""
+	}
+
+	r.mu.RLock()
+	state := r.Services[service]
+	rd := r.redactor
+	r.mu.RUnlock()
+
+	// Secrets never reach buffers, log files or clients. Color codes can
+	// split a secret, so colored text is dropped rather than redacted.
+	if redacted := rd.redact(text); redacted != text {
+		text = redacted
+		styled = ""
+	}
+
+	// Readiness probes see all output, regardless of display filters
+	if state != nil {
+		state.matchReadyLog(text)
+	}
 
 	if r.exclude != nil && r.exclude.MatchString(text) {
 		return
@@ -710,42 +1163,76 @@ func (r *Runner) processLine(service, text string, isError bool) {
 		return
 	}
 
-	level := "info"
-	lowerText := strings.ToLower(text)
-	if strings.Contains(lowerText, "error") || strings.Contains(lowerText, "fail") || isError {
-		level = "error"
-	} else if strings.Contains(lowerText, "warn") {
-		level = "warn"
-	} else if strings.Contains(lowerText, "debug") {
-		level = "debug"
+	// Only lifecycle messages come with a level
+	stream := types.StreamStdout
+	switch {
+	case level != "":
+		stream = types.StreamDevir
+	case isError:
+		stream = types.StreamStderr
+	}
+
+	// The time from a structured line is only used for display
+	var entryTime time.Time
+	var fields map[string]string
+
+	var svc config.Service
+	if state != nil {
+		svc = state.Service
+	}
+
+	if level == "" {
+		if sl, ok := parseStructured(svc, text); ok {
+			text = sl.Message
+			styled = ""
+			level = sl.Level
+			fields = sl.Fields
+			entryTime = sl.Time
+		}
+	}
+
+	if level == "" {
+		level = classifyLevel(svc, text, isError)
 	}
 
 	line := types.LogLine{
-		Service:   service,
-		Text:      text,
-		Timestamp: time.Now(),
-		IsError:   isError,
+		Service: service,
+		Text:    text,
+		IsError: isError,
+		Level:   level,
+		Fields:  fields,
+		Styled:  styled,
+		Stream:  stream,
 	}
 
-	r.mu.RLock()
-	state := r.Services[service]
-	r.mu.RUnlock()
+	// Stored lines get the arrival time and the next sequence number under
+	// one lock, so buffers, log files and LogEntryChan see lines in the same
+	// order
+	r.logMu.Lock()
+	r.logSeq++
+	line.Seq = r.logSeq
+	line.Timestamp = time.Now()
+	if entryTime.IsZero() {
+		entryTime = line.Timestamp
+	}
 
 	if state != nil {
-		state.Mu.Lock()
-		state.Logs = append(state.Logs, line)
-		if len(state.Logs) > 1000 {
-			state.Logs = state.Logs[len(state.Logs)-1000:]
+		state.Logs.Append(line)
+		if state.logFile != nil {
+			_ = state.logFile.write(line)
 		}
-		state.Mu.Unlock()
 	}
 
 	if r.tuiMode {
 		entry := types.LogEntry{
-			Time:    time.Now(),
+			Time:    entryTime,
 			Level:   level,
 			Service: service,
 			Message: text,
+			Fields:  fields,
+			Styled:  styled,
+			Seq:     line.Seq,
+			Stream:  stream,
 		}
 		select {
 		case r.LogEntryChan <- entry:
@@ -757,6 +1244,19 @@ func (r *Runner) processLine(service, text string, isError bool) {
 		default:
 		}
 	}
+	r.logMu.Unlock()
+}
+
+// forwardLogChan routes lifecycle messages sent to LogChan through
+// processLine, so they are stored and reach LogEntryChan in TUI mode
+func (r *Runner) forwardLogChan() {
+	for line := range r.LogChan {
+		level := "info"
+		if line.IsError {
+			level = "error"
+		}
+		r.processLine(line.Service, line.Text, line.IsError, level)
+	}
 }
 
 func (r *Runner) printLogs() {
@@ -794,7 +1294,12 @@ func (r *Runner) printLogs() {
 
 		prefix := fmt.Sprintf("%s[%s]%s", c, line.Service, reset)
 		text := line.Text
-		if line.IsError {
+		// Lifecycle messages sent straight to LogChan have no level and
+		// haven't been through processLine
+		if line.Level == "" {
+			text = r.currentRedactor().redact(text)
+		}
+		if line.Level == "error" || (line.Level == "" && line.IsError) {
 			text = errorColor + text + reset
 		}
 
@@ -816,9 +1321,8 @@ func (r *Runner) GetServices() map[string]types.ServiceInfo {
 
 	result := make(map[string]types.ServiceInfo)
 	for name, state := range r.Services {
+		logs := state.Logs.Snapshot()
 		state.Mu.Lock()
-		logs := make([]types.LogLine, len(state.Logs))
-		copy(logs, state.Logs)
 
 		// Check for dynamic status from .devir-status file
 		icon := state.Service.Icon
@@ -826,7 +1330,7 @@ func (r *Runner) GetServices() map[string]types.ServiceInfo {
 		status := state.Status
 		message := ""
 
-		if ds := r.readDynamicStatus(state); ds != nil {
+		if ds := readDynamicStatus(r.Config.RootDir, state); ds != nil {
 			if ds.Icon != "" {
 				icon = ds.Icon
 			}
@@ -850,20 +1354,23 @@ func (r *Runner) GetServices() map[string]types.ServiceInfo {
 		}
 
 		result[name] = types.ServiceInfo{
-			Name:     name,
-			Color:    color,
-			Icon:     icon,
-			Running:  state.Running,
-			Logs:     logs,
-			Type:     string(state.Service.GetEffectiveType()),
-			Status:   status,
-			LastRun:  state.LastRun,
-			NextRun:  state.NextRun,
-			ExitCode: state.ExitCode,
-			RunCount: state.RunCount,
-			Message:  message,
-			CPU:      cpu,
-			Memory:   memory,
+			Name:       name,
+			Color:      color,
+			Icon:       icon,
+			Running:    state.Running,
+			Logs:       logs,
+			Type:       string(state.Service.GetEffectiveType()),
+			Status:     status,
+			LastRun:    state.LastRun,
+			NextRun:    state.NextRun,
+			ExitCode:   state.ExitCode,
+			RunCount:   state.RunCount,
+			Restarts:   state.Restarts,
+			CrashLoop:  state.CrashLoop,
+			StopResult: state.StopResult,
+			Message:    message,
+			CPU:        cpu,
+			Memory:     memory,
 		}
 		state.Mu.Unlock()
 	}
@@ -872,8 +1379,8 @@ func (r *Runner) GetServices() map[string]types.ServiceInfo {
 
 // readDynamicStatus reads status from .devir-status file in service directory
 // Supports both plain text (just icon) and JSON format
-func (r *Runner) readDynamicStatus(state *ServiceState) *types.DynamicStatus {
-	statusFile := filepath.Join(r.Config.RootDir, state.Service.Dir, ".devir-status")
+func readDynamicStatus(rootDir string, state *ServiceState) *types.DynamicStatus {
+	statusFile := filepath.Join(rootDir, state.Service.Dir, ".devir-status")
 	data, err := os.ReadFile(statusFile)
 	if err != nil {
 		return nil
@@ -0,0 +1,244 @@
+package runner
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"devir/internal/config"
+	"devir/internal/types"
+)
+
+// ignoredDirs are never watched, regardless of the exclude globs
+var ignoredDirs = map[string]bool{
+	".git":         true,
+	".devir":       true,
+	"node_modules": true,
+}
+
+// ensureWatcher starts the file watcher for a service if it has a watch
+// block and no watcher is running yet. The watcher lives until the service
+// is stopped explicitly, so it survives restarts and crashes.
+func (r *Runner) ensureWatcher(name string, state *ServiceState) {
+	if state.Service.Watch == nil {
+		return
+	}
+
+	state.Mu.Lock()
+	defer state.Mu.Unlock()
+	if state.watchStop != nil {
+		return
+	}
+	state.watchStop = make(chan struct{})
+	go r.watchFiles(name, state, state.watchStop)
+}
+
+// stopWatcher stops the file watcher of a service, if any
+func (s *ServiceState) stopWatcher() {
+	s.Mu.Lock()
+	defer s.Mu.Unlock()
+	if s.watchStop != nil {
+		close(s.watchStop)
+		s.watchStop = nil
+	}
+}
+
+// watchFiles restarts a service when files matching its watch globs change.
+// Changes are debounced so a burst of writes causes a single restart.
+func (r *Runner) watchFiles(name string, state *ServiceState, stop <-chan struct{}) {
+	wc := state.Service.Watch
+	root := filepath.Join(r.currentConfig().RootDir, state.Service.Dir)
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		r.LogChan <- types.LogLine{
+			Service:   name,
+			Text:      "[watch] Failed to start: " + err.Error(),
+			Timestamp: time.Now(),
+			IsError:   true,
+		}
+		return
+	}
+	defer func() { _ = watcher.Close() }()
+
+	// fsnotify is not recursive, so every directory is added separately
+	addWatchDirs(watcher, root, root, wc)
+
+	changed := make(map[string]bool)
+	var debounce <-chan time.Time
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+
+			rel, err := filepath.Rel(root, event.Name)
+			if err != nil {
+				continue
+			}
+			rel = filepath.ToSlash(rel)
+
+			// Pick up directories created after startup
+			if event.Has(fsnotify.Create) {
+				addWatchDirs(watcher, root, event.Name, wc)
+			}
+
+			if event.Op == fsnotify.Chmod || !watchMatches(wc, rel) {
+				continue
+			}
+
+			changed[rel] = true
+			debounce = time.After(wc.Debounce)
+
+		case <-debounce:
+			debounce = nil
+
+			files := make([]string, 0, len(changed))
+			for f := range changed {
+				files = append(files, f)
+			}
+			sort.Strings(files)
+			changed = make(map[string]bool)
+
+			// Don't bring back a service the user stopped
+			state.Mu.Lock()
+			stopping := state.stopping
+			state.Mu.Unlock()
+			if stopping {
+				continue
+			}
+
+			r.LogChan <- types.LogLine{
+				Service:   name,
+				Text:      "[watch] Restarting: " + describeChanges(files),
+				Timestamp: time.Now(),
+			}
+			r.RestartService(name)
+
+		case <-watcher.Errors:
+			// Errors (e.g. queue overflow) are not fatal; keep watching
+		}
+	}
+}
+
+// addWatchDirs adds dir and all directories below it that are not excluded
+func addWatchDirs(watcher *fsnotify.Watcher, root, dir string, wc *config.WatchConfig) {
+	_ = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if rel != "." && (ignoredDirs[d.Name()] || watchDirExcluded(wc, rel)) {
+			return filepath.SkipDir
+		}
+		_ = watcher.Add(p)
+		return nil
+	})
+}
+
+// watchMatches reports whether a changed file (relative to the service dir)
+// should trigger a restart
+func watchMatches(wc *config.WatchConfig, rel string) bool {
+	for _, part := range strings.Split(rel, "/") {
+		if ignoredDirs[part] {
+			return false
+		}
+	}
+	if path.Base(rel) == ".devir-status" {
+		return false
+	}
+
+	if len(wc.Include) > 0 {
+		included := false
+		for _, pattern := range wc.Include {
+			if matchGlob(pattern, rel) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return false
+		}
+	}
+
+	for _, pattern := range wc.Exclude {
+		if matchGlob(pattern, rel) {
+			return false
+		}
+	}
+	return true
+}
+
+// watchDirExcluded reports whether a whole directory is excluded, either
+// directly or by a "dir/**" glob
+func watchDirExcluded(wc *config.WatchConfig, rel string) bool {
+	for _, pattern := range wc.Exclude {
+		if matchGlob(pattern, rel) || matchGlob(strings.TrimSuffix(pattern, "/**"), rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob matches a slash-separated path against a glob. "**" matches any
+// number of path segments; a glob without a slash matches the base name.
+func matchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(name))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(parts); i++ {
+				if matchSegments(pattern[1:], parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+// describeChanges formats the changed files for the restart log line
+func describeChanges(files []string) string {
+	const maxShown = 3
+	if len(files) == 1 {
+		return files[0] + " changed"
+	}
+	shown := files
+	if len(shown) > maxShown {
+		shown = shown[:maxShown]
+	}
+	text := fmt.Sprintf("%d files changed (%s", len(files), strings.Join(shown, ", "))
+	if len(files) > maxShown {
+		text += fmt.Sprintf(", +%d more", len(files)-maxShown)
+	}
+	return text + ")"
+}
@@ -0,0 +1,103 @@
+package tui
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// keySequences are the terminal input sequences of special keys
+var keySequences = map[tea.KeyType]string{
+	tea.KeyUp:       "\x1b[A",
+	tea.KeyDown:     "\x1b[B",
+	tea.KeyRight:    "\x1b[C",
+	tea.KeyLeft:     "\x1b[D",
+	tea.KeyHome:     "\x1b[H",
+	tea.KeyEnd:      "\x1b[F",
+	tea.KeyPgUp:     "\x1b[5~",
+	tea.KeyPgDown:   "\x1b[6~",
+	tea.KeyInsert:   "\x1b[2~",
+	tea.KeyDelete:   "\x1b[3~",
+	tea.KeyShiftTab: "\x1b[Z",
+	tea.KeySpace:    " ",
+}
+
+// keyBytes returns the bytes a terminal would send for a key press
+func keyBytes(msg tea.KeyMsg) []byte {
+	var s string
+	switch {
+	case msg.Type == tea.KeyRunes:
+		s = string(msg.Runes)
+	case msg.Type >= 0 && msg.Type <= 127:
+		// Control keys are their own byte (ctrl+c, enter, tab, ...)
+		s = string(rune(msg.Type))
+	default:
+		s = keySequences[msg.Type]
+	}
+	if s == "" {
+		return nil
+	}
+	if msg.Alt {
+		s = "\x1b" + s
+	}
+	return []byte(s)
+}
+
+// updateAttached handles a key press while attached to a service. Services
+// with a tty get every key as typed; others get whole lines from the input
+// field, since nothing would echo or edit what is typed.
+func (m *Model) updateAttached(msg tea.KeyMsg) tea.Cmd {
+	if msg.Type == tea.KeyCtrlCloseBracket {
+		m.detach()
+		return nil
+	}
+
+	service := m.services[m.activeTab]
+	if m.serviceTTY(service) {
+		if data := keyBytes(msg); data != nil {
+			m.writeStdin(service, data)
+		}
+		return nil
+	}
+
+	if msg.Type == tea.KeyEnter {
+		m.writeStdin(service, []byte(m.stdinInput.Value()+"\n"))
+		m.stdinInput.SetValue("")
+		return nil
+	}
+	var cmd tea.Cmd
+	m.stdinInput, cmd = m.stdinInput.Update(msg)
+	return cmd
+}
+
+func (m *Model) detach() {
+	m.attached = false
+	m.stdinInput.Blur()
+	m.stdinInput.SetValue("")
+}
+
+// serviceTTY reports whether a service runs under a pseudo-terminal
+func (m *Model) serviceTTY(name string) bool {
+	if m.clientMode {
+		return m.cfg.Services[name].TTY
+	}
+	if state, ok := m.Runner.Services[name]; ok {
+		return state.Service.TTY
+	}
+	return false
+}
+
+// writeStdin sends input to a service. In client mode failures come back
+// from the daemon as error messages.
+func (m *Model) writeStdin(service string, data []byte) {
+	var err error
+	if m.clientMode {
+		err = m.client.Stdin(service, data)
+	} else {
+		err = m.Runner.WriteStdin(service, data)
+	}
+	if err != nil {
+		m.statusMsg = err.Error()
+		m.statusTime = time.Now()
+	}
+}
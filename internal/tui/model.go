@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os/exec"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
@@ -40,8 +41,21 @@ type Model struct {
 	searchQuery string
 	autoScroll  bool
 	clientMode  bool
-	statusMsg   string    // Temporary status message (e.g., "Copied!")
-	statusTime  time.Time // When to clear status message
+	folded      bool            // Show only the first line of multiline entries
+	toggled     map[uint64]bool // Seq of multiline entries folded the other way
+	multiline   []entryLines    // Where multiline entries were last rendered
+	attached    bool            // Keys go to the active service's stdin
+	stdinInput  textinput.Model // Line input when attached to a service without a tty
+	statusMsg   string          // Temporary status message (e.g., "Copied!")
+	statusTime  time.Time       // When to clear status message
+
+	statusPolled time.Time // When status was last requested from the daemon
+}
+
+// entryLines are the viewport lines a log entry was rendered to
+type entryLines struct {
+	seq         uint64
+	first, last int
 }
 
 // tickMsg is sent periodically to update logs
@@ -65,6 +79,7 @@ func New(r *runner.Runner) Model {
 		activeTab:   -1, // All
 		logs:        make([]types.LogEntry, 0, 1000),
 		searchInput: ti,
+		stdinInput:  newStdinInput(),
 		autoScroll:  true,
 		clientMode:  false,
 	}
@@ -84,11 +99,20 @@ func NewWithClient(client *daemon.Client, services []string, cfg *config.Config)
 		activeTab:   -1, // All
 		logs:        make([]types.LogEntry, 0, 1000),
 		searchInput: ti,
+		stdinInput:  newStdinInput(),
 		autoScroll:  true,
 		clientMode:  true,
 	}
 }
 
+// newStdinInput creates the line input of attached services without a tty
+func newStdinInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = "> "
+	ti.CharLimit = 1000
+	return ti
+}
+
 // Init initializes the model
 func (m Model) Init() tea.Cmd {
 	if m.clientMode {
@@ -114,7 +138,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		if m.searching {
+		if m.attached {
+			cmds = append(cmds, m.updateAttached(msg))
+		} else if m.searching {
 			switch msg.String() {
 			case "esc":
 				m.searching = false
@@ -132,9 +158,9 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.String() {
 			case "q", "ctrl+c":
 				m.quitting = true
-				if m.clientMode {
-					_ = m.client.Stop()
-				} else {
+				// Services belong to the daemon; an embedded one is
+				// stopped by main once the program exits
+				if !m.clientMode {
 					m.Runner.Stop()
 				}
 				return m, tea.Quit
@@ -169,6 +195,17 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.searchInput.Focus()
 				cmds = append(cmds, textinput.Blink)
 
+			case "i":
+				// Attach: send keys to the current service until ctrl+]
+				if m.activeTab >= 0 && m.activeTab < len(m.services) {
+					m.attached = true
+					m.autoScroll = true
+					if !m.serviceTTY(m.services[m.activeTab]) {
+						m.stdinInput.Focus()
+						cmds = append(cmds, textinput.Blink)
+					}
+				}
+
 			case "r":
 				if m.activeTab >= 0 && m.activeTab < len(m.services) {
 					if m.clientMode {
@@ -195,6 +232,16 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				}
 				m.clearLocalLogs(service)
 
+			case "z":
+				// Fold/unfold the multiline entry (stack trace) in view
+				m.toggleFold()
+
+			case "Z":
+				// Fold/unfold all multiline entries
+				m.folded = !m.folded
+				m.toggled = nil
+				m.updateViewport()
+
 			case "up", "k":
 				m.viewport.ScrollUp(1)
 				m.autoScroll = false
@@ -245,8 +292,12 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tickMsg:
 		if m.clientMode {
 			m.collectClientLogs()
-			// Periodically request status
-			_ = m.client.Status()
+			// Status changes are pushed by daemons that support it, so
+			// polling only refreshes CPU and memory
+			if !m.client.HasCapability("status_events") || time.Since(m.statusPolled) >= time.Second {
+				_ = m.client.Status()
+				m.statusPolled = time.Now()
+			}
 		} else {
 			m.collectLogs()
 		}
@@ -297,6 +348,10 @@ func (m *Model) collectClientLogs() {
 						Level:   logData.Level,
 						Service: logData.Service,
 						Message: logData.Message,
+						Fields:  logData.Fields,
+						Styled:  logData.Styled,
+						Seq:     logData.Seq,
+						Stream:  logData.Stream,
 					})
 					if len(m.logs) > 2000 {
 						m.logs = m.logs[len(m.logs)-2000:]
@@ -307,6 +362,22 @@ func (m *Model) collectClientLogs() {
 				for _, s := range resp.Services {
 					m.statuses[s.Name] = s
 				}
+			case daemon.MsgStatusChanged:
+				s, err := daemon.ParsePayload[daemon.ServiceStatus](msg)
+				if err == nil {
+					// Events carry no metrics; keep the last polled ones
+					prev := m.statuses[s.Name]
+					s.CPU, s.Memory = prev.CPU, prev.Memory
+					m.statuses[s.Name] = s
+				}
+			case daemon.MsgConfigChanged:
+				if change, err := daemon.ParsePayload[daemon.ConfigChange](msg); err == nil {
+					m.applyConfigChange(change)
+				}
+			case daemon.MsgError:
+				resp, _ := daemon.ParsePayload[daemon.ErrorResponse](msg)
+				m.statusMsg = resp.Error
+				m.statusTime = time.Now()
 			}
 		default:
 			return
@@ -314,8 +385,49 @@ func (m *Model) collectClientLogs() {
 	}
 }
 
+// applyConfigChange follows a reload of devir.yaml in the daemon: tabs of
+// removed services go away and services started by the reload get one
+func (m *Model) applyConfigChange(change daemon.ConfigChange) {
+	m.statusTime = time.Now()
+	if change.Error != "" {
+		m.statusMsg = "Config reload failed: " + change.Error
+		return
+	}
+	m.statusMsg = "Config reloaded"
+
+	// Colors and icons of new services come from our copy of the config
+	if cfg, err := config.Load(m.cfg.Path); err == nil {
+		m.cfg = cfg
+	}
+
+	active := ""
+	if m.activeTab >= 0 && m.activeTab < len(m.services) {
+		active = m.services[m.activeTab]
+	}
+
+	var services []string
+	for _, name := range m.services {
+		if !slices.Contains(change.Removed, name) {
+			services = append(services, name)
+		}
+	}
+	for _, name := range change.Started {
+		if !slices.Contains(services, name) {
+			services = append(services, name)
+		}
+	}
+	m.services = services
+
+	m.activeTab = slices.Index(m.services, active)
+	for _, name := range change.Removed {
+		delete(m.statuses, name)
+	}
+	m.updateViewport()
+}
+
 func (m *Model) updateViewport() {
-	content := m.renderLogs()
+	content, multiline := m.renderLogs()
+	m.multiline = multiline
 	m.viewport.SetContent(content)
 
 	if m.autoScroll {
@@ -323,6 +435,38 @@ func (m *Model) updateViewport() {
 	}
 }
 
+// toggleFold folds or unfolds the lowest multiline entry in view, which is
+// the newest one while following the logs
+func (m *Model) toggleFold() {
+	top := m.viewport.YOffset
+	bottom := top + m.viewport.Height
+	for i := len(m.multiline) - 1; i >= 0; i-- {
+		e := m.multiline[i]
+		if e.first >= bottom || e.last < top {
+			continue
+		}
+		if m.toggled == nil {
+			m.toggled = make(map[uint64]bool)
+		}
+		if m.toggled[e.seq] {
+			delete(m.toggled, e.seq)
+		} else {
+			m.toggled[e.seq] = true
+		}
+		m.updateViewport()
+		// Keep the entry in view when its end moves up
+		if e.first < m.viewport.YOffset {
+			m.viewport.SetYOffset(e.first)
+		}
+		return
+	}
+}
+
+// isFolded reports whether a multiline entry shows only its first line
+func (m *Model) isFolded(entry types.LogEntry) bool {
+	return m.folded != m.toggled[entry.Seq]
+}
+
 // GetFilteredLogs returns logs filtered by active tab and search query
 func (m *Model) GetFilteredLogs() []types.LogEntry {
 	var filtered []types.LogEntry
@@ -449,6 +593,7 @@ func equalIgnoreCase(a, b string) bool {
 func (m *Model) clearLocalLogs(service string) {
 	if service == "" {
 		m.logs = nil
+		m.toggled = nil
 	} else {
 		filtered := make([]types.LogEntry, 0)
 		for _, log := range m.logs {
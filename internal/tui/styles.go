@@ -44,6 +44,10 @@ var (
 	DebugStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("8"))
 
+	// Extra fields of structured log lines
+	FieldsStyle = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("244"))
+
 	// Status bar styles
 	StatusBarStyle = lipgloss.NewStyle().
 			BorderStyle(lipgloss.NormalBorder()).
@@ -69,6 +73,24 @@ var (
 	StatusWaiting = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("11"))
 
+	StatusStarting = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("11"))
+
+	StatusHealthy = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("10")).
+			Bold(true)
+
+	StatusUnhealthy = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("9")).
+			Bold(true)
+
+	StatusRestarting = lipgloss.NewStyle().
+				Foreground(lipgloss.Color("11"))
+
+	StatusCrashLoop = lipgloss.NewStyle().
+			Foreground(lipgloss.Color("9")).
+			Bold(true)
+
 	// Help style
 	HelpStyle = lipgloss.NewStyle().
 			Foreground(lipgloss.Color("240"))
@@ -81,6 +103,9 @@ var (
 			Bold(true)
 )
 
+// ansiReset ends the colors of styled service output
+const ansiReset = "\x1b[0m"
+
 // GetServiceStyle returns a styled prefix for a service
 func GetServiceStyle(color string) lipgloss.Style {
 	c, ok := ServiceColors[color]
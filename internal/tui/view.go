@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+
+	"devir/internal/runner"
 )
 
 // View renders the UI
@@ -82,13 +84,27 @@ func getStatusSymbol(status string) string {
 		return "✗"
 	case "waiting":
 		return "◐"
+	case "starting":
+		return "◌"
+	case "healthy":
+		return "●"
+	case "unhealthy":
+		return "◉"
+	case "restarting":
+		return "↻"
+	case "crashloop":
+		return "⟲"
 	default:
 		return "○"
 	}
 }
 
-func (m Model) renderLogs() string {
+// renderLogs renders the filtered logs and where each multiline entry ended
+// up, for folding them one at a time
+func (m Model) renderLogs() (string, []entryLines) {
 	var b strings.Builder
+	var multiline []entryLines
+	lineNo := 0
 	logs := m.GetFilteredLogs()
 
 	for _, entry := range logs {
@@ -109,12 +125,37 @@ func (m Model) renderLogs() string {
 
 		level := levelStyle.Render(fmt.Sprintf("%-5s", strings.ToUpper(entry.Level)))
 		service := serviceStyle.Render(fmt.Sprintf("[%s]", entry.Service))
-		line := fmt.Sprintf("%s %s %s\n", level, service, entry.Message)
+		message := entry.Message
+		if entry.Styled != "" {
+			// Reset at every line end so colors don't bleed into the prefix
+			message = strings.ReplaceAll(entry.Styled, "\n", ansiReset+"\n") + ansiReset
+		}
+		isMultiline := false
+		if first, rest, ok := strings.Cut(message, "\n"); ok {
+			isMultiline = true
+			if m.isFolded(entry) {
+				more := strings.Count(rest, "\n") + 1
+				message = first + " " + DebugStyle.Render(fmt.Sprintf("▸ +%d lines", more))
+			} else {
+				// Align continuation lines under the first line's text
+				indent := strings.Repeat(" ", lipgloss.Width(level)+lipgloss.Width(service)+2)
+				message = first + "\n" + indent + strings.ReplaceAll(rest, "\n", "\n"+indent)
+			}
+		}
+		if len(entry.Fields) > 0 {
+			message += " " + FieldsStyle.Render(runner.FormatFields(entry.Fields))
+		}
+		line := fmt.Sprintf("%s %s %s\n", level, service, message)
 
+		lines := strings.Count(line, "\n")
+		if isMultiline {
+			multiline = append(multiline, entryLines{seq: entry.Seq, first: lineNo, last: lineNo + lines - 1})
+		}
+		lineNo += lines
 		b.WriteString(line)
 	}
 
-	return b.String()
+	return b.String(), multiline
 }
 
 func (m Model) renderStatusBar() string {
@@ -208,6 +249,16 @@ func getStyledStatus(status string) string {
 		return StatusFailed.Render("✗")
 	case "waiting":
 		return StatusWaiting.Render("◐")
+	case "starting":
+		return StatusStarting.Render("◌")
+	case "healthy":
+		return StatusHealthy.Render("●")
+	case "unhealthy":
+		return StatusUnhealthy.Render("◉")
+	case "restarting":
+		return StatusRestarting.Render("↻")
+	case "crashloop":
+		return StatusCrashLoop.Render("⟲")
 	default:
 		return StatusStopped.Render("○")
 	}
@@ -218,11 +269,23 @@ func (m Model) renderHelp() string {
 		return "Search: " + m.searchInput.View()
 	}
 
+	if m.attached {
+		service := m.services[m.activeTab]
+		hint := HelpStyle.Render(fmt.Sprintf("Attached to %s │ ctrl+]: detach", service))
+		if !m.serviceTTY(service) {
+			hint = m.stdinInput.View() + "  " + hint
+		}
+		if m.statusMsg != "" {
+			hint += "  " + HelpStyle.Render(m.statusMsg)
+		}
+		return hint
+	}
+
 	// Show status message if present
 	if m.statusMsg != "" {
 		return HelpStyle.Render(m.statusMsg)
 	}
 
-	help := "Tab: switch │ 1-9: select │ a: all │ /: search │ c: copy │ x: clear │ r: restart │ q: quit"
+	help := "Tab: switch │ 1-9: select │ a: all │ /: search │ c: copy │ x: clear │ r: restart │ i: attach │ z/Z: fold │ q: quit"
 	return HelpStyle.Render(help)
 }
@@ -0,0 +1,130 @@
+package types
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// logChunkSize is the number of lines per chunk of a LogBuffer
+const logChunkSize = 256
+
+// LogBuffer is a fixed-capacity ring buffer of log lines, safe for concurrent
+// use. Lines are stored in chunks that are never modified once written, so
+// snapshots share memory with the buffer instead of copying it.
+type LogBuffer struct {
+	mu       sync.Mutex
+	capacity int
+	chunks   [][]LogLine // oldest first; only the last chunk is appended to
+	start    int         // index of the oldest live line in chunks[0]
+	size     int         // number of live lines
+}
+
+// NewLogBuffer creates a buffer that keeps the last capacity lines
+func NewLogBuffer(capacity int) *LogBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &LogBuffer{capacity: capacity}
+}
+
+// Append adds a line, dropping the oldest one if the buffer is full
+func (b *LogBuffer) Append(line LogLine) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	last := len(b.chunks) - 1
+	if last < 0 || len(b.chunks[last]) == logChunkSize {
+		b.chunks = append(b.chunks, make([]LogLine, 0, logChunkSize))
+		last++
+	}
+	b.chunks[last] = append(b.chunks[last], line)
+	b.size++
+
+	if b.size > b.capacity {
+		b.start++
+		b.size--
+		if b.start == logChunkSize {
+			// Drop the whole chunk; snapshots holding it keep it alive
+			b.chunks[0] = nil
+			b.chunks = b.chunks[1:]
+			b.start = 0
+		}
+	}
+}
+
+// Len returns the number of lines in the buffer
+func (b *LogBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// Clear removes all lines
+func (b *LogBuffer) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.chunks = nil
+	b.start = 0
+	b.size = 0
+}
+
+// Snapshot returns a read-only view of the current lines. Only the chunk
+// list is copied; later appends are not visible in the snapshot.
+func (b *LogBuffer) Snapshot() LogSnapshot {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	chunks := make([][]LogLine, len(b.chunks))
+	for i, c := range b.chunks {
+		// Cap the length so the snapshot ignores lines appended later
+		chunks[i] = c[:len(c):len(c)]
+	}
+	return LogSnapshot{chunks: chunks, start: b.start, size: b.size}
+}
+
+// Since returns a copy of the lines logged at or after t, oldest first
+func (b *LogBuffer) Since(t time.Time) []LogLine {
+	return b.Snapshot().Since(t)
+}
+
+// LogSnapshot is an immutable view of a LogBuffer at one point in time
+type LogSnapshot struct {
+	chunks [][]LogLine
+	start  int
+	size   int
+}
+
+// Len returns the number of lines in the snapshot
+func (s LogSnapshot) Len() int {
+	return s.size
+}
+
+// At returns the i-th line, 0 being the oldest
+func (s LogSnapshot) At(i int) LogLine {
+	i += s.start
+	return s.chunks[i/logChunkSize][i%logChunkSize]
+}
+
+// Lines returns a copy of all lines, oldest first
+func (s LogSnapshot) Lines() []LogLine {
+	return s.Tail(s.size)
+}
+
+// Tail returns a copy of the last n lines, oldest first
+func (s LogSnapshot) Tail(n int) []LogLine {
+	n = min(max(n, 0), s.size)
+	lines := make([]LogLine, 0, n)
+	for i := s.size - n; i < s.size; i++ {
+		lines = append(lines, s.At(i))
+	}
+	return lines
+}
+
+// Since returns a copy of the lines logged at or after t, oldest first.
+// Lines are appended in time order, so the first one is found by binary
+// search.
+func (s LogSnapshot) Since(t time.Time) []LogLine {
+	i := sort.Search(s.size, func(i int) bool { return !s.At(i).Timestamp.Before(t) })
+	return s.Tail(s.size - i)
+}
@@ -6,11 +6,24 @@ import "time"
 type ServiceStatus string
 
 const (
-	StatusStopped   ServiceStatus = "stopped"   // Not running
-	StatusRunning   ServiceStatus = "running"   // Long-running service active
-	StatusCompleted ServiceStatus = "completed" // Oneshot completed successfully
-	StatusFailed    ServiceStatus = "failed"    // Oneshot or interval failed
-	StatusWaiting   ServiceStatus = "waiting"   // Interval waiting for next run
+	StatusStopped    ServiceStatus = "stopped"    // Not running
+	StatusRunning    ServiceStatus = "running"    // Long-running service active
+	StatusCompleted  ServiceStatus = "completed"  // Oneshot completed successfully
+	StatusFailed     ServiceStatus = "failed"     // Oneshot or interval failed
+	StatusWaiting    ServiceStatus = "waiting"    // Interval waiting for next run
+	StatusStarting   ServiceStatus = "starting"   // Started, readiness probe not yet passed
+	StatusHealthy    ServiceStatus = "healthy"    // Readiness probe passed
+	StatusUnhealthy  ServiceStatus = "unhealthy"  // Readiness probe failing
+	StatusRestarting ServiceStatus = "restarting" // Exited, waiting for backoff before restarting
+	StatusCrashLoop  ServiceStatus = "crashloop"  // Restarting repeatedly without staying up
+)
+
+// StopResult describes how a requested stop ended
+type StopResult string
+
+const (
+	StopGraceful StopResult = "graceful" // Exited after the stop signal
+	StopKilled   StopResult = "killed"   // Force killed after stop_timeout
 )
 
 // DynamicStatus is written by services to .devir-status file
@@ -21,12 +34,24 @@ type DynamicStatus struct {
 	Status  string `json:"status,omitempty"`  // Override status (running, completed, failed, waiting)
 }
 
+// Streams a log line can come from
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+	StreamDevir  = "devir" // lifecycle messages of devir itself, e.g. "Stopped"
+)
+
 // LogLine represents a single log line from a service
 type LogLine struct {
 	Service   string
 	Text      string
 	Timestamp time.Time
 	IsError   bool
+	Level     string            // info, warn, error, debug
+	Fields    map[string]string // extra fields of a structured (json/logfmt) line
+	Styled    string            // Text with its ANSI colors, empty if it has none
+	Seq       uint64            // increases with every stored line of any service, 0 if read from a log file
+	Stream    string            // stdout, stderr or devir
 }
 
 // LogEntry represents a structured log entry for TUI
@@ -35,22 +60,29 @@ type LogEntry struct {
 	Level   string // info, warn, error, debug
 	Service string
 	Message string
+	Fields  map[string]string // extra fields of a structured (json/logfmt) line
+	Styled  string            // Message with its ANSI colors, empty if it has none
+	Seq     uint64            // Seq of the stored line
+	Stream  string            // stdout, stderr or devir
 }
 
 // ServiceInfo provides service status for TUI
 type ServiceInfo struct {
-	Name     string
-	Color    string
-	Icon     string // custom icon/emoji
-	Running  bool
-	Logs     []LogLine
-	Type     string        // service, oneshot, interval, http
-	Status   ServiceStatus // detailed status
-	LastRun  time.Time     // last execution time
-	NextRun  time.Time     // next scheduled run (for interval)
-	ExitCode int           // last exit code
-	RunCount int           // number of runs (for interval)
-	Message  string        // dynamic status message from .devir-status
-	CPU      float64       // CPU percentage (0-100 per core, can exceed 100 with multiple cores)
-	Memory   uint64        // Memory in bytes (RSS)
+	Name       string
+	Color      string
+	Icon       string // custom icon/emoji
+	Running    bool
+	Logs       LogSnapshot
+	Type       string        // service, oneshot, interval, http
+	Status     ServiceStatus // detailed status
+	LastRun    time.Time     // last execution time
+	NextRun    time.Time     // next scheduled run (for interval)
+	ExitCode   int           // last exit code
+	RunCount   int           // number of runs (for interval)
+	Restarts   int           // consecutive automatic restarts
+	CrashLoop  bool          // restarting repeatedly without staying up
+	StopResult StopResult    // how the last requested stop ended
+	Message    string        // dynamic status message from .devir-status
+	CPU        float64       // CPU percentage (0-100 per core, can exceed 100 with multiple cores)
+	Memory     uint64        // Memory in bytes (RSS)
 }